@@ -0,0 +1,104 @@
+package GTFS
+
+import (
+	"time"
+)
+
+const (
+	gtfsRealtimeVersion = "2.0"
+	alertsLanguage      = "pl"
+)
+
+// AlertPeriod is a time range in which an alert is in effect. Missing Start or End leaves the range open.
+type AlertPeriod struct {
+	Start *time.Time `json:",omitempty"`
+	End   *time.Time `json:",omitempty"`
+}
+
+// Alert is a service alert published in the GTFS-Realtime ServiceAlerts feed
+type Alert struct {
+	ID          string
+	URL         string
+	Header      string
+	Description string
+	RouteIDs    []string
+	// set for alerts without routes, which apply to whole agencies
+	AgencyIDs     []string `json:",omitempty"`
+	ActivePeriods []AlertPeriod
+}
+
+// AlertSource returns alerts in effect at given time
+type AlertSource func(now time.Time) ([]Alert, error)
+
+// withAgencies makes alerts without routes apply to given agencies, as every alert needs an informed entity
+func withAgencies(alerts []Alert, agencyIDs []string) {
+	for i := range alerts {
+		if len(alerts[i].RouteIDs) == 0 {
+			alerts[i].AgencyIDs = agencyIDs
+		}
+	}
+}
+
+// encodeAlerts returns a full dataset GTFS-Realtime FeedMessage with alerts.
+// Alerts without routes and agencies are left out, as they can't be valid.
+func encodeAlerts(alerts []Alert, timestamp time.Time) []byte {
+	var header, feed protoBuffer
+	header.stringField(1, gtfsRealtimeVersion)
+	header.uintField(2, 0) // FULL_DATASET
+	header.uintField(3, uint64(timestamp.Unix()))
+	feed.bytesField(1, header)
+
+	for _, alert := range alerts {
+		if len(alert.RouteIDs) == 0 && len(alert.AgencyIDs) == 0 {
+			continue
+		}
+
+		var entity protoBuffer
+		entity.stringField(1, alert.ID)
+		entity.bytesField(5, encodeAlert(alert))
+		feed.bytesField(2, entity)
+	}
+	return feed
+}
+
+func encodeAlert(alert Alert) []byte {
+	var message protoBuffer
+	for _, period := range alert.ActivePeriods {
+		var timeRange protoBuffer
+		if period.Start != nil {
+			timeRange.uintField(1, uint64(period.Start.Unix()))
+		}
+		if period.End != nil {
+			timeRange.uintField(2, uint64(period.End.Unix()))
+		}
+		message.bytesField(1, timeRange)
+	}
+
+	for _, routeID := range alert.RouteIDs {
+		var selector protoBuffer
+		selector.stringField(2, routeID)
+		message.bytesField(5, selector)
+	}
+	for _, agencyID := range alert.AgencyIDs {
+		var selector protoBuffer
+		selector.stringField(1, agencyID)
+		message.bytesField(5, selector)
+	}
+
+	if alert.URL != "" {
+		message.bytesField(8, translatedString(alert.URL))
+	}
+	message.bytesField(10, translatedString(alert.Header))
+	if alert.Description != "" {
+		message.bytesField(11, translatedString(alert.Description))
+	}
+	return message
+}
+
+func translatedString(text string) []byte {
+	var translation, message protoBuffer
+	translation.stringField(1, text)
+	translation.stringField(2, alertsLanguage)
+	message.bytesField(1, translation)
+	return message
+}
@@ -0,0 +1,110 @@
+package GTFS
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// describeAlerts decodes a FeedMessage and describes every alert in it with a line of text
+func describeAlerts(t *testing.T, data []byte) (string, []string) {
+	fields, err := parseProto(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var header string
+	var alerts []string
+	for _, field := range fields {
+		message, err := parseProto(field.bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if field.number == 1 {
+			var parts []string
+			for _, f := range message {
+				if f.wireType == wireBytes {
+					parts = append(parts, f.string())
+				} else {
+					parts = append(parts, fmt.Sprint(f.value))
+				}
+			}
+			header = strings.Join(parts, " ")
+			continue
+		}
+
+		var id string
+		var parts []string
+		for _, f := range message {
+			if f.number == 1 {
+				id = f.string()
+				continue
+			}
+			alert, err := parseProto(f.bytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, a := range alert {
+				inner, err := parseProto(a.bytes)
+				if err != nil {
+					t.Fatal(err)
+				}
+				switch a.number {
+				case 1:
+					for _, bound := range inner {
+						parts = append(parts, fmt.Sprintf("period%d=%d", bound.number, bound.value))
+					}
+				case 5:
+					if inner[0].number == 1 {
+						parts = append(parts, "agency="+inner[0].string())
+					} else {
+						parts = append(parts, "route="+inner[0].string())
+					}
+				default:
+					translation, err := parseProto(inner[0].bytes)
+					if err != nil {
+						t.Fatal(err)
+					}
+					parts = append(parts, fmt.Sprintf("%d=%s/%s", a.number, translation[0].string(), translation[1].string()))
+				}
+			}
+		}
+		alerts = append(alerts, id+": "+strings.Join(parts, " "))
+	}
+	return header, alerts
+}
+
+func TestEncodeAlerts(t *testing.T) {
+	start := time.Unix(1540800000, 0)
+	end := time.Unix(1540900000, 0)
+	alerts := []Alert{
+		{
+			ID:            "1",
+			URL:           "http://mpk.wroc.pl/1",
+			Header:        "Objazd",
+			Description:   "Tramwaje jadą objazdem",
+			RouteIDs:      []string{"33", "A"},
+			ActivePeriods: []AlertPeriod{{Start: &start, End: &end}, {Start: &end}},
+		},
+		{ID: "2", Header: "Zmiany"},
+	}
+	withAgencies(alerts, []string{"2"})
+	// without routes and agencies alert would be invalid
+	alerts = append(alerts, Alert{ID: "3", Header: "Objazd"})
+
+	header, result := describeAlerts(t, encodeAlerts(alerts, time.Unix(1540850000, 0)))
+	if header != "2.0 0 1540850000" {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, header, "2.0 0 1540850000")
+	}
+
+	expected := []string{
+		"1: period1=1540800000 period2=1540900000 period1=1540900000 route=33 route=A 8=http://mpk.wroc.pl/1/pl 10=Objazd/pl 11=Tramwaje jadą objazdem/pl",
+		"2: agency=2 10=Zmiany/pl",
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, expected)
+	}
+}
@@ -0,0 +1,134 @@
+package GTFS
+
+import (
+	"time"
+)
+
+const dateLayout = "20060102"
+
+// GTFS exception types used in calendar_dates.txt
+const (
+	serviceAdded   = "1"
+	serviceRemoved = "2"
+)
+
+type service struct {
+	weekdays [7]bool
+	start    string
+	end      string
+	added    map[string]bool
+	removed  map[string]bool
+}
+
+// ServiceCalendar answers whether a service runs on given date,
+// based on calendar.txt and exceptions from calendar_dates.txt.
+// On holidays, services without feed's exceptions for the date run if they serve the day type chosen by HolidayCalendar.
+type ServiceCalendar struct {
+	services map[int]*service
+	holidays *HolidayCalendar
+}
+
+func NewServiceCalendar(calendars []FeedCalendar, dates []FeedCalendarDate, holidays *HolidayCalendar) *ServiceCalendar {
+	calendar := &ServiceCalendar{map[int]*service{}, holidays}
+
+	for _, c := range calendars {
+		s := calendar.service(c.ServiceID)
+		s.weekdays = c.Weekdays
+		s.start = c.StartDate
+		s.end = c.EndDate
+	}
+
+	for _, date := range dates {
+		s := calendar.service(date.ServiceID)
+		switch date.ExceptionType {
+		case serviceAdded:
+			s.added[date.Date] = true
+		case serviceRemoved:
+			s.removed[date.Date] = true
+		}
+	}
+
+	return calendar
+}
+
+func (calendar *ServiceCalendar) service(serviceID int) *service {
+	s, ok := calendar.services[serviceID]
+	if !ok {
+		s = &service{added: map[string]bool{}, removed: map[string]bool{}}
+		calendar.services[serviceID] = s
+	}
+	return s
+}
+
+// IsActive tells whether service runs on given date. Only date part (in date's location) is taken into account.
+func (calendar *ServiceCalendar) IsActive(serviceID int, date time.Time) bool {
+	s, ok := calendar.services[serviceID]
+	if !ok {
+		return false
+	}
+
+	key := date.Format(dateLayout)
+	if s.removed[key] {
+		return false
+	}
+	if s.added[key] {
+		return true
+	}
+
+	// dates in YYYYMMDD format can be compared as strings
+	if s.start == "" || key < s.start || key > s.end {
+		return false
+	}
+
+	// operator knows best: holiday rules are used only for services without an exception for this date
+	if dayType, special := calendar.holidays.DayType(date); special {
+		return s.weekdays[dayTypeWeekdays[dayType]]
+	}
+	return s.weekdays[date.Weekday()]
+}
+
+// DayType returns type of service running on given date.
+func (calendar *ServiceCalendar) DayType(date time.Time) DayType {
+	dayType, _ := calendar.holidays.DayType(date)
+	return dayType
+}
+
+// RunsOnWeekday tells whether service regularly runs on given day of the week.
+// Services defined only by calendar_dates.txt run on weekdays of their added dates.
+func (calendar *ServiceCalendar) RunsOnWeekday(serviceID int, weekday time.Weekday) bool {
+	s, ok := calendar.services[serviceID]
+	if !ok {
+		return false
+	}
+	if s.start != "" {
+		return s.weekdays[weekday]
+	}
+
+	for key := range s.added {
+		date, err := time.Parse(dateLayout, key)
+		if err == nil && date.Weekday() == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// days of the week which represent each day type in timetables.
+// in Wrocław, Fridays have a separate service which is identical to Monday-Thursday one,
+// so Wednesday is used to pick only one of them.
+var dayTypeWeekdays = map[DayType]time.Weekday{
+	Weekday:  time.Wednesday,
+	Saturday: time.Saturday,
+	Sunday:   time.Sunday,
+}
+
+// DayTypes returns timetable day types served by given service.
+func (calendar *ServiceCalendar) DayTypes(serviceID int) []DayType {
+	var dayTypes []DayType
+	for _, dayType := range []DayType{Weekday, Saturday, Sunday} {
+		if calendar.RunsOnWeekday(serviceID, dayTypeWeekdays[dayType]) {
+			dayTypes = append(dayTypes, dayType)
+		}
+	}
+	return dayTypes
+}
@@ -0,0 +1,53 @@
+package GTFS
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestServiceCalendar(t *testing.T) {
+	calendars := []FeedCalendar{
+		{6, [7]bool{false, true, true, true, true, false, false}, "20181001", "20181231"},
+		{3, [7]bool{false, false, false, false, false, false, true}, "20181001", "20181231"},
+	}
+	dates := []FeedCalendarDate{
+		// 1 November is a holiday, Saturday service runs instead
+		{6, "20181101", "2"},
+		{3, "20181101", "1"},
+		// special service running only on a single Sunday
+		{12, "20181104", "1"},
+	}
+	calendar := NewServiceCalendar(calendars, dates, nil)
+
+	tables := []struct {
+		serviceID int
+		date      string
+		expected  bool
+	}{
+		{6, "20181031", true},
+		{6, "20181101", false},
+		{6, "20181102", false},
+		{3, "20181101", true},
+		{3, "20181103", true},
+		{6, "20190102", false},
+		{12, "20181104", true},
+		{12, "20181111", false},
+		{99, "20181031", false},
+	}
+
+	for _, table := range tables {
+		date, _ := time.Parse(dateLayout, table.date)
+		result := calendar.IsActive(table.serviceID, date)
+		if result != table.expected {
+			t.Errorf(`Wrong result for service %d on %s. Got %v, expected: %v`, table.serviceID, table.date, result, table.expected)
+		}
+	}
+
+	if dayTypes := calendar.DayTypes(6); !reflect.DeepEqual(dayTypes, []DayType{Weekday}) {
+		t.Errorf("Wrong day types for service 6: %v", dayTypes)
+	}
+	if dayTypes := calendar.DayTypes(12); !reflect.DeepEqual(dayTypes, []DayType{Sunday}) {
+		t.Errorf("Wrong day types for service 12: %v", dayTypes)
+	}
+}
@@ -0,0 +1,498 @@
+package GTFS
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maximum number of error messages kept per file. everything above that is only counted.
+const maxReportedErrors = 50
+
+type FileReport struct {
+	Name       string
+	Missing    bool
+	Rows       int
+	ErrorCount int
+	Errors     []string
+}
+
+func (report *FileReport) addError(line int, err error) {
+	report.ErrorCount++
+	if len(report.Errors) < maxReportedErrors {
+		report.Errors = append(report.Errors, fmt.Sprintf("line %d: %s", line, err))
+	}
+}
+
+type ImportReport struct {
+	Files []FileReport
+}
+
+func (report ImportReport) HasErrors() bool {
+	for _, file := range report.Files {
+		if file.ErrorCount > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (report ImportReport) String() string {
+	var sb strings.Builder
+	for _, file := range report.Files {
+		if file.Missing {
+			fmt.Fprintf(&sb, "%-20s missing\n", file.Name)
+			continue
+		}
+		fmt.Fprintf(&sb, "%-20s %8d rows, %d errors\n", file.Name, file.Rows, file.ErrorCount)
+		for _, msg := range file.Errors {
+			fmt.Fprintf(&sb, "    %s\n", msg)
+		}
+	}
+	return sb.String()
+}
+
+type FeedAgency struct {
+	AgencyID int
+	Name     string
+	Url      string
+	Phone    string
+}
+
+type FeedRoute struct {
+	RouteID    string
+	AgencyID   int
+	TypeID     int
+	ValidFrom  string
+	ValidUntil string
+	// hex without "#", empty if feed doesn't set it
+	Color string
+}
+
+type FeedRouteType struct {
+	TypeID int
+	Name   string
+}
+
+type FeedTrip struct {
+	TripID    int
+	RouteID   string
+	ServiceID int
+	VehicleID int
+	VariantID int
+	Headsign  string
+	ShapeID   int
+}
+
+type FeedStop struct {
+	StopID    int
+	Code      int
+	Name      string
+	Latitude  float64
+	Longitude float64
+}
+
+type FeedStopTime struct {
+	TripID        int
+	ArrivalTime   string
+	DepartureTime string
+	StopID        int
+	StopSequence  int
+	OnDemand      bool
+}
+
+type FeedVehicleType struct {
+	VehicleID   int
+	Name        string
+	Description string
+	Symbol      string
+}
+
+type FeedInfo struct {
+	PublisherName string
+	PublisherURL  string
+	Lang          string
+	StartDate     string
+	EndDate       string
+	Version       string
+}
+
+type FeedCalendar struct {
+	ServiceID int
+	// indexed by time.Weekday
+	Weekdays  [7]bool
+	StartDate string
+	EndDate   string
+}
+
+type FeedCalendarDate struct {
+	ServiceID     int
+	Date          string
+	ExceptionType string
+}
+
+type FeedShapePoint struct {
+	ShapeID       int
+	ShapeSequence int
+	Latitude      float64
+	Longitude     float64
+}
+
+// Feed holds the contents of a GTFS zip, already converted to the types used by the graph.
+type Feed struct {
+	Agencies      []FeedAgency
+	Routes        []FeedRoute
+	RouteTypes    []FeedRouteType
+	Trips         []FeedTrip
+	Stops         []FeedStop
+	StopTimes     []FeedStopTime
+	VehicleTypes  []FeedVehicleType
+	FeedInfo      []FeedInfo
+	Calendars     []FeedCalendar
+	CalendarDates []FeedCalendarDate
+	ShapePoints   []FeedShapePoint
+
+	Report ImportReport
+}
+
+// csvRow gives access to a CSV record by column name.
+// parse errors are accumulated in err, so that a row can be read field by field
+// and checked only once at the end.
+type csvRow struct {
+	columns map[string]int
+	record  []string
+	err     error
+}
+
+func (row *csvRow) str(column string) string {
+	idx, ok := row.columns[column]
+	if !ok || idx >= len(row.record) {
+		return ""
+	}
+	return strings.TrimSpace(row.record[idx])
+}
+
+func (row *csvRow) required(column string) string {
+	value := row.str(column)
+	if value == "" && row.err == nil {
+		row.err = fmt.Errorf(`missing value for "%s"`, column)
+	}
+	return value
+}
+
+func (row *csvRow) integer(column string) int {
+	value := row.str(column)
+	if value == "" {
+		return 0
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil && row.err == nil {
+		row.err = fmt.Errorf(`invalid integer "%s" in "%s"`, value, column)
+	}
+	return i
+}
+
+func (row *csvRow) float(column string) float64 {
+	value := row.str(column)
+	if value == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil && row.err == nil {
+		row.err = fmt.Errorf(`invalid number "%s" in "%s"`, value, column)
+	}
+	return f
+}
+
+// Wrocław trip IDs look like "3_14613106". underscore is dropped, so that they can be stored as integers.
+func parseTripID(value string) (int, error) {
+	return strconv.Atoi(strings.Replace(value, "_", "", -1))
+}
+
+func (row *csvRow) tripID(column string) int {
+	i, err := parseTripID(row.required(column))
+	if err != nil && row.err == nil {
+		row.err = fmt.Errorf(`invalid trip ID "%s" in "%s"`, row.str(column), column)
+	}
+	return i
+}
+
+// hoursMinutes reads GTFS time ("H:MM:SS" or "HH:MM:SS", hours can exceed 23) and keeps it as "HH:MM".
+func (row *csvRow) hoursMinutes(column string) string {
+	value := row.required(column)
+	seconds, err := ParseServiceTime(value)
+	if err != nil {
+		if row.err == nil {
+			row.err = fmt.Errorf(`invalid time "%s" in "%s"`, value, column)
+		}
+		return value
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/3600, seconds%3600/60)
+}
+
+func readCSV(file *zip.File, report *FileReport, handleRow func(row *csvRow)) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	reader := csv.NewReader(rc)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	columns := map[string]int{}
+	for idx, name := range header {
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.TrimSpace(name)] = idx
+	}
+
+	// header is line 1
+	line := 1
+	for {
+		record, err := reader.Read()
+		line++
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			report.addError(line, err)
+			continue
+		}
+
+		row := csvRow{columns: columns, record: record}
+		handleRow(&row)
+		if row.err != nil {
+			report.addError(line, row.err)
+			continue
+		}
+		report.Rows++
+	}
+
+	return nil
+}
+
+type feedFile struct {
+	name     string
+	required bool
+	parse    func(feed *Feed, row *csvRow)
+}
+
+var feedFiles = []feedFile{
+	{"agency.txt", true, func(feed *Feed, row *csvRow) {
+		agency := FeedAgency{
+			AgencyID: row.integer("agency_id"),
+			Name:     row.required("agency_name"),
+			Url:      row.str("agency_url"),
+			Phone:    row.str("agency_phone"),
+		}
+		if row.err == nil {
+			feed.Agencies = append(feed.Agencies, agency)
+		}
+	}},
+	{"routes.txt", true, func(feed *Feed, row *csvRow) {
+		route := FeedRoute{
+			RouteID:    row.required("route_id"),
+			AgencyID:   row.integer("agency_id"),
+			TypeID:     row.integer("route_type2_id"),
+			ValidFrom:  row.str("valid_from"),
+			ValidUntil: row.str("valid_until"),
+			Color:      row.str("route_color"),
+		}
+		if row.err == nil {
+			feed.Routes = append(feed.Routes, route)
+		}
+	}},
+	{"route_types.txt", false, func(feed *Feed, row *csvRow) {
+		routeType := FeedRouteType{
+			TypeID: row.integer("route_type2_id"),
+			Name:   row.required("route_type2_name"),
+		}
+		if row.err == nil {
+			feed.RouteTypes = append(feed.RouteTypes, routeType)
+		}
+	}},
+	{"trips.txt", true, func(feed *Feed, row *csvRow) {
+		trip := FeedTrip{
+			TripID:    row.tripID("trip_id"),
+			RouteID:   row.required("route_id"),
+			ServiceID: row.integer("service_id"),
+			VehicleID: row.integer("vehicle_id"),
+			VariantID: row.integer("variant_id"),
+			Headsign:  row.str("trip_headsign"),
+			ShapeID:   row.integer("shape_id"),
+		}
+		if row.err == nil {
+			feed.Trips = append(feed.Trips, trip)
+		}
+	}},
+	{"stops.txt", true, func(feed *Feed, row *csvRow) {
+		stop := FeedStop{
+			StopID:    row.integer("stop_id"),
+			Code:      row.integer("stop_code"),
+			Name:      row.required("stop_name"),
+			Latitude:  row.float("stop_lat"),
+			Longitude: row.float("stop_lon"),
+		}
+		if row.err == nil {
+			feed.Stops = append(feed.Stops, stop)
+		}
+	}},
+	{"stop_times.txt", true, func(feed *Feed, row *csvRow) {
+		stopTime := FeedStopTime{
+			TripID:        row.tripID("trip_id"),
+			ArrivalTime:   row.hoursMinutes("arrival_time"),
+			DepartureTime: row.hoursMinutes("departure_time"),
+			StopID:        row.integer("stop_id"),
+			StopSequence:  row.integer("stop_sequence"),
+			OnDemand:      row.integer("drop_off_type") == 3,
+		}
+		if row.err == nil {
+			feed.StopTimes = append(feed.StopTimes, stopTime)
+		}
+	}},
+	{"vehicle_types.txt", false, func(feed *Feed, row *csvRow) {
+		vehicleType := FeedVehicleType{
+			VehicleID:   row.integer("vehicle_type_id"),
+			Name:        row.str("vehicle_type_name"),
+			Description: row.str("vehicle_type_description"),
+			Symbol:      row.str("vehicle_type_symbol"),
+		}
+		if row.err == nil {
+			feed.VehicleTypes = append(feed.VehicleTypes, vehicleType)
+		}
+	}},
+	{"feed_info.txt", false, func(feed *Feed, row *csvRow) {
+		info := FeedInfo{
+			PublisherName: row.str("feed_publisher_name"),
+			PublisherURL:  row.str("feed_publisher_url"),
+			Lang:          row.str("feed_lang"),
+			StartDate:     row.str("feed_start_date"),
+			EndDate:       row.str("feed_end_date"),
+			Version:       row.str("feed_version"),
+		}
+		if row.err == nil {
+			feed.FeedInfo = append(feed.FeedInfo, info)
+		}
+	}},
+	{"calendar.txt", false, func(feed *Feed, row *csvRow) {
+		calendar := FeedCalendar{
+			ServiceID: row.integer("service_id"),
+			Weekdays: [7]bool{
+				row.integer("sunday") == 1,
+				row.integer("monday") == 1,
+				row.integer("tuesday") == 1,
+				row.integer("wednesday") == 1,
+				row.integer("thursday") == 1,
+				row.integer("friday") == 1,
+				row.integer("saturday") == 1,
+			},
+			StartDate: row.required("start_date"),
+			EndDate:   row.required("end_date"),
+		}
+		if row.err == nil {
+			feed.Calendars = append(feed.Calendars, calendar)
+		}
+	}},
+	{"calendar_dates.txt", false, func(feed *Feed, row *csvRow) {
+		date := FeedCalendarDate{
+			ServiceID:     row.integer("service_id"),
+			Date:          row.required("date"),
+			ExceptionType: row.required("exception_type"),
+		}
+		if row.err == nil {
+			feed.CalendarDates = append(feed.CalendarDates, date)
+		}
+	}},
+	{"shapes.txt", false, func(feed *Feed, row *csvRow) {
+		point := FeedShapePoint{
+			ShapeID:       row.integer("shape_id"),
+			ShapeSequence: row.integer("shape_pt_sequence"),
+			Latitude:      row.float("shape_pt_lat"),
+			Longitude:     row.float("shape_pt_lon"),
+		}
+		if row.err == nil {
+			feed.ShapePoints = append(feed.ShapePoints, point)
+		}
+	}},
+}
+
+// ReadFeed parses GTFS zip at given path. Malformed rows are skipped and recorded in feed's report,
+// error is returned only when the archive itself can't be read or a required file is missing.
+func ReadFeed(path string) (*Feed, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	return readFeed(&archive.Reader)
+}
+
+func readFeed(archive *zip.Reader) (*Feed, error) {
+	files := map[string]*zip.File{}
+	for _, file := range archive.File {
+		// some feeds are zipped together with parent directory
+		name := file.Name[strings.LastIndex(file.Name, "/")+1:]
+		files[name] = file
+	}
+
+	feed := &Feed{}
+	for _, ff := range feedFiles {
+		report := FileReport{Name: ff.name}
+
+		file, ok := files[ff.name]
+		if !ok {
+			if ff.required {
+				return nil, fmt.Errorf(`required file "%s" is missing from the feed`, ff.name)
+			}
+			report.Missing = true
+			feed.Report.Files = append(feed.Report.Files, report)
+			continue
+		}
+
+		parse := ff.parse
+		err := readCSV(file, &report, func(row *csvRow) { parse(feed, row) })
+		if err != nil {
+			return nil, fmt.Errorf(`reading "%s": %s`, ff.name, err)
+		}
+		feed.Report.Files = append(feed.Report.Files, report)
+	}
+
+	return feed, nil
+}
+
+// stopTimesByTrip groups stop times by trip ID. every group is sorted by stop sequence.
+func (feed *Feed) stopTimesByTrip() map[int][]FeedStopTime {
+	stopTimes := map[int][]FeedStopTime{}
+	for _, st := range feed.StopTimes {
+		stopTimes[st.TripID] = append(stopTimes[st.TripID], st)
+	}
+	for _, sts := range stopTimes {
+		sort.Slice(sts, func(i, j int) bool {
+			return sts[i].StopSequence < sts[j].StopSequence
+		})
+	}
+	return stopTimes
+}
+
+// feedVersion identifies feed by its feed_version, or by its validity dates if it's not set.
+func feedVersion(infos []FeedInfo) string {
+	if len(infos) == 0 {
+		return ""
+	}
+	if infos[0].Version != "" {
+		return infos[0].Version
+	}
+	return infos[0].StartDate + "-" + infos[0].EndDate
+}
@@ -0,0 +1,106 @@
+package GTFS
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+func makeZip(t *testing.T, files map[string]string) *zip.Reader {
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+var minimalFeed = map[string]string{
+	"agency.txt": "\ufeffagency_id,agency_name,agency_url,agency_phone\n" +
+		"2,MPK Wrocław,http://mpk.wroc.pl,71 321 72 71\n",
+	"routes.txt": "route_id,agency_id,route_type2_id,valid_from,valid_until\n" +
+		"33,2,31,2018-09-17,2999-01-01\n",
+	"trips.txt": "route_id,service_id,trip_id,trip_headsign,shape_id,variant_id,vehicle_id\n" +
+		"33,6,6_5521453,PILCZYCE,100,9,3\n" +
+		"33,6,broken,PILCZYCE,100,9,3\n",
+	"stops.txt": "stop_id,stop_code,stop_name,stop_lat,stop_lon\n" +
+		"1,20001,Plac Grunwaldzki,51.11,17.06\n" +
+		"2,20002,Pilczyce,51.13,north\n",
+	"stop_times.txt": "trip_id,arrival_time,departure_time,stop_id,stop_sequence,pickup_type,drop_off_type\n" +
+		"6_5521453,25:10:00,25:10:00,1,0,0,3\n" +
+		"6_5521453,25:20:00,25:21:00,2,1,0,0\n",
+}
+
+func TestReadFeed(t *testing.T) {
+	feed, err := readFeed(makeZip(t, minimalFeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(feed.Agencies) != 1 || feed.Agencies[0].AgencyID != 2 {
+		t.Errorf("Wrong agencies: %v", feed.Agencies)
+	}
+
+	if len(feed.Trips) != 1 || feed.Trips[0].TripID != 65521453 {
+		t.Errorf("Wrong trips: %v", feed.Trips)
+	}
+
+	if len(feed.Stops) != 1 {
+		t.Errorf("Expected malformed stop to be skipped, got %v", feed.Stops)
+	}
+
+	expected := []FeedStopTime{
+		{65521453, "25:10", "25:10", 1, 0, true},
+		{65521453, "25:20", "25:21", 2, 1, false},
+	}
+	if len(feed.StopTimes) != len(expected) {
+		t.Fatalf("Wrong stop times: %v", feed.StopTimes)
+	}
+	for i := range expected {
+		if feed.StopTimes[i] != expected[i] {
+			t.Errorf(`Wrong stop time. Got "%v", expected: "%v"`, feed.StopTimes[i], expected[i])
+		}
+	}
+
+	reports := map[string]FileReport{}
+	for _, file := range feed.Report.Files {
+		reports[file.Name] = file
+	}
+	if r := reports["trips.txt"]; r.Rows != 1 || r.ErrorCount != 1 {
+		t.Errorf("Wrong report for trips.txt: %v", r)
+	}
+	if r := reports["stops.txt"]; r.Rows != 1 || r.ErrorCount != 1 {
+		t.Errorf("Wrong report for stops.txt: %v", r)
+	}
+	if r := reports["shapes.txt"]; !r.Missing {
+		t.Errorf("Expected shapes.txt to be reported as missing: %v", r)
+	}
+	if !feed.Report.HasErrors() {
+		t.Error("Expected report to have errors")
+	}
+}
+
+func TestReadFeedMissingRequiredFile(t *testing.T) {
+	files := map[string]string{}
+	for name, content := range minimalFeed {
+		if name != "stop_times.txt" {
+			files[name] = content
+		}
+	}
+
+	if _, err := readFeed(makeZip(t, files)); err == nil {
+		t.Error("Expected error for feed without stop_times.txt")
+	}
+}
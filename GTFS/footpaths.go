@@ -0,0 +1,55 @@
+package GTFS
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// in m/s, straight line distance is used so it's a bit lower than actual walking speed
+	walkingSpeed = 1.1
+	// default maximum walk between two platforms, in metres
+	DefaultTransferRadius = 400.0
+)
+
+func walkingTime(distance float64) int {
+	return int(math.Ceil(distance / walkingSpeed))
+}
+
+// Transfer is a walk from one platform to another.
+type Transfer struct {
+	Stop
+	// in metres
+	Distance float64
+	// walking time in seconds
+	Duration int
+}
+
+// footpaths maps stop ID to walks to all other stops within radius, nearest first.
+type footpaths map[int][]Transfer
+
+func newFootpaths(index *stopIndex, radius float64) footpaths {
+	result := footpaths{}
+	for _, stop := range index.stops {
+		for _, nearby := range index.near(stop.Latitude, stop.Longitude, radius, 0) {
+			if nearby.ID == stop.ID {
+				continue
+			}
+			distance := math.Round(nearby.Distance)
+			result[stop.ID] = append(result[stop.ID], Transfer{nearby.Stop, distance, walkingTime(distance)})
+		}
+	}
+	return result
+}
+
+// transfersFrom returns walks from given stop, never nil.
+func (paths footpaths) transfersFrom(stopID int) []Transfer {
+	transfers := paths[stopID]
+	if transfers == nil {
+		return []Transfer{}
+	}
+	sort.SliceStable(transfers, func(i, j int) bool {
+		return transfers[i].Distance < transfers[j].Distance
+	})
+	return transfers
+}
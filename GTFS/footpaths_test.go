@@ -0,0 +1,28 @@
+package GTFS
+
+import (
+	"testing"
+)
+
+func TestNewFootpaths(t *testing.T) {
+	stops := []Stop{
+		{"B", 2, 51.1100, 17.0000, 0},
+		// 200 m from B
+		{"C", 3, 51.1118, 17.0000, 0},
+		// 2 km from both
+		{"D", 4, 51.1300, 17.0000, 0},
+	}
+	paths := newFootpaths(newStopIndex(stops, nil), DefaultTransferRadius)
+
+	transfers := paths.transfersFrom(2)
+	if len(transfers) != 1 || transfers[0].ID != 3 {
+		t.Fatalf("Wrong transfers: %v", transfers)
+	}
+	if transfers[0].Distance != 200 || transfers[0].Duration != 182 {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, transfers[0], Transfer{stops[1], 200, 182})
+	}
+
+	if transfers := paths.transfersFrom(4); len(transfers) != 0 {
+		t.Errorf("Wrong transfers: %v", transfers)
+	}
+}
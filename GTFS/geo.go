@@ -0,0 +1,51 @@
+package GTFS
+
+import "math"
+
+const earthRadius = 6371000.0
+
+// distance returns great-circle distance between two points in metres.
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+type BoundingBox struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+}
+
+// extend grows box to include given point. Zero box is treated as empty.
+func (box BoundingBox) extend(lat, lon float64) BoundingBox {
+	if box == (BoundingBox{}) {
+		return BoundingBox{lat, lon, lat, lon}
+	}
+	return BoundingBox{
+		math.Min(box.MinLatitude, lat),
+		math.Min(box.MinLongitude, lon),
+		math.Max(box.MaxLatitude, lat),
+		math.Max(box.MaxLongitude, lon),
+	}
+}
+
+func (box BoundingBox) Contains(lat, lon float64) bool {
+	return lat >= box.MinLatitude && lat <= box.MaxLatitude && lon >= box.MinLongitude && lon <= box.MaxLongitude
+}
+
+// bearing returns initial direction from the first point to the second one, in degrees clockwise from north.
+func bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLon := toRadians(lon2 - lon1)
+	y := math.Sin(dLon) * math.Cos(toRadians(lat2))
+	x := math.Cos(toRadians(lat1))*math.Sin(toRadians(lat2)) -
+		math.Sin(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Cos(dLon)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
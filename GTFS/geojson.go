@@ -0,0 +1,93 @@
+package GTFS
+
+import "strings"
+
+// Minimal GeoJSON (RFC 7946) types. Positions are [longitude, latitude].
+
+type GeoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   GeoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+func newGeoJSONFeature(geometry GeoJSONGeometry, properties map[string]interface{}) GeoJSONFeature {
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	return GeoJSONFeature{"Feature", geometry, properties}
+}
+
+func newGeoJSONFeatureCollection(features []GeoJSONFeature) GeoJSONFeatureCollection {
+	if features == nil {
+		features = []GeoJSONFeature{}
+	}
+	return GeoJSONFeatureCollection{"FeatureCollection", features}
+}
+
+func geoJSONPosition(latitude, longitude float64) [2]float64 {
+	return [2]float64{longitude, latitude}
+}
+
+// colours used when feed doesn't set route_color
+const (
+	tramColor  = "D2232A"
+	busColor   = "0066B3"
+	nightColor = "1A1A1A"
+)
+
+// routeColor returns "#RRGGBB" colour of a route, from the feed or based on its type.
+func routeColor(feedColor, routeType string) string {
+	if feedColor != "" {
+		return "#" + strings.ToUpper(feedColor)
+	}
+
+	routeType = strings.ToLower(routeType)
+	switch {
+	case strings.Contains(routeType, "nocn"):
+		return "#" + nightColor
+	case strings.Contains(routeType, "tram"):
+		return "#" + tramColor
+	default:
+		return "#" + busColor
+	}
+}
+
+// GeoJSON returns shapes as LineStrings followed by stops as Points, all tagged with route's colour.
+func (data MapData) GeoJSON(color string) GeoJSONFeatureCollection {
+	features := make([]GeoJSONFeature, 0, len(data.Shapes)+len(data.Stops))
+	for _, shape := range data.Shapes {
+		line := make([][2]float64, len(shape.Points))
+		for i, point := range shape.Points {
+			line[i] = geoJSONPosition(float64(point.Latitude), float64(point.Longitude))
+		}
+		features = append(features, newGeoJSONFeature(GeoJSONGeometry{"LineString", line}, map[string]interface{}{
+			"ShapeID": shape.ShapeID,
+			"RouteID": data.RouteID,
+			"Color":   color,
+		}))
+	}
+
+	for _, stop := range data.Stops {
+		point := geoJSONPosition(stop.Latitude, stop.Longitude)
+		features = append(features, newGeoJSONFeature(GeoJSONGeometry{"Point", point}, map[string]interface{}{
+			"ID":          stop.ID,
+			"Name":        stop.Name,
+			"Code":        stop.Code,
+			"OnDemand":    stop.OnDemand,
+			"FirstOrLast": stop.FirstOrLast,
+			"RouteID":     data.RouteID,
+			"Color":       color,
+		}))
+	}
+	return newGeoJSONFeatureCollection(features)
+}
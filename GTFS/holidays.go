@@ -0,0 +1,153 @@
+package GTFS
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+func (dayType DayType) MarshalText() ([]byte, error) {
+	switch dayType {
+	case Weekday:
+		return []byte("weekday"), nil
+	case Saturday:
+		return []byte("saturday"), nil
+	case Sunday:
+		return []byte("sunday"), nil
+	}
+	return nil, fmt.Errorf("unknown day type %d", dayType)
+}
+
+func (dayType *DayType) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "weekday":
+		*dayType = Weekday
+	case "saturday":
+		*dayType = Saturday
+	case "sunday":
+		*dayType = Sunday
+	default:
+		return fmt.Errorf(`unknown day type "%s"`, text)
+	}
+	return nil
+}
+
+func dayTypeOfWeekday(weekday time.Weekday) DayType {
+	switch weekday {
+	case time.Saturday:
+		return Saturday
+	case time.Sunday:
+		return Sunday
+	}
+	return Weekday
+}
+
+// Anonymous Gregorian algorithm
+func easterSunday(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// polishHolidays returns public holidays (days free from work) in given year, keyed by date in YYYYMMDD format.
+func polishHolidays(year int) map[string]string {
+	fixed := func(month time.Month, day int) string {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Format(dateLayout)
+	}
+	easter := easterSunday(year)
+	movable := func(days int) string {
+		return easter.AddDate(0, 0, days).Format(dateLayout)
+	}
+
+	holidays := map[string]string{
+		fixed(time.January, 1):   "Nowy Rok",
+		fixed(time.January, 6):   "Święto Trzech Króli",
+		movable(0):               "Wielkanoc",
+		movable(1):               "Poniedziałek Wielkanocny",
+		fixed(time.May, 1):       "Święto Pracy",
+		fixed(time.May, 3):       "Święto Konstytucji 3 Maja",
+		movable(49):              "Zielone Świątki",
+		movable(60):              "Boże Ciało",
+		fixed(time.August, 15):   "Wniebowzięcie Najświętszej Maryi Panny",
+		fixed(time.November, 1):  "Wszystkich Świętych",
+		fixed(time.November, 11): "Narodowe Święto Niepodległości",
+		fixed(time.December, 25): "Boże Narodzenie",
+		fixed(time.December, 26): "Drugi dzień Bożego Narodzenia",
+	}
+	// Christmas Eve is a public holiday since 2025
+	if year >= 2025 {
+		holidays[fixed(time.December, 24)] = "Wigilia Bożego Narodzenia"
+	}
+	return holidays
+}
+
+// HolidayCalendar decides which kind of service (weekday, Saturday or Sunday) runs on given date.
+// Polish public holidays run on Sunday service. Extra dates supplied by admin take precedence over that.
+type HolidayCalendar struct {
+	// key -> date in YYYYMMDD format
+	extra map[string]DayType
+}
+
+func NewHolidayCalendar(extra map[string]DayType) *HolidayCalendar {
+	if extra == nil {
+		extra = map[string]DayType{}
+	}
+	return &HolidayCalendar{extra}
+}
+
+// LoadHolidayCalendar reads extra dates from JSON file mapping dates to day types, e.g.
+//
+//	{"2018-12-24": "saturday", "2018-12-31": "saturday"}
+func LoadHolidayCalendar(path string) (*HolidayCalendar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var data map[string]DayType
+	if err := json.NewDecoder(file).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	extra := map[string]DayType{}
+	for key, dayType := range data {
+		date, err := time.Parse("2006-01-02", key)
+		if err != nil {
+			return nil, err
+		}
+		extra[date.Format(dateLayout)] = dayType
+	}
+	return NewHolidayCalendar(extra), nil
+}
+
+// DayType returns day type of service running on given date.
+// second value is true if it's different from what day of the week would suggest.
+func (holidays *HolidayCalendar) DayType(date time.Time) (DayType, bool) {
+	regular := dayTypeOfWeekday(date.Weekday())
+	if holidays == nil {
+		return regular, false
+	}
+
+	key := date.Format(dateLayout)
+	if dayType, ok := holidays.extra[key]; ok {
+		return dayType, dayType != regular
+	}
+	if _, ok := polishHolidays(date.Year())[key]; ok {
+		return Sunday, regular != Sunday
+	}
+	return regular, false
+}
@@ -0,0 +1,85 @@
+package GTFS
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEasterSunday(t *testing.T) {
+	tables := []struct {
+		year     int
+		expected string
+	}{
+		{2018, "20180401"},
+		{2019, "20190421"},
+		{2024, "20240331"},
+		{2026, "20260405"},
+	}
+
+	for _, table := range tables {
+		result := easterSunday(table.year).Format(dateLayout)
+		if result != table.expected {
+			t.Errorf(`Wrong Easter date for %d. Got "%s", expected: "%s"`, table.year, result, table.expected)
+		}
+	}
+}
+
+func TestHolidayCalendarDayType(t *testing.T) {
+	holidays := NewHolidayCalendar(map[string]DayType{
+		"20181224": Saturday,
+		// holiday overridden by admin
+		"20181111": Weekday,
+	})
+
+	tables := []struct {
+		date     string
+		expected DayType
+		special  bool
+	}{
+		{"20181031", Weekday, false},
+		{"20181101", Sunday, true},    // All Saints' Day
+		{"20180402", Sunday, true},    // Easter Monday
+		{"20180531", Sunday, true},    // Corpus Christi
+		{"20181103", Saturday, false}, // regular Saturday
+		{"20181224", Saturday, true},
+		{"20181111", Weekday, true},
+		{"20180520", Sunday, false}, // Pentecost is on Sunday anyway
+		{"20251224", Sunday, true},
+	}
+
+	for _, table := range tables {
+		date, _ := time.Parse(dateLayout, table.date)
+		dayType, special := holidays.DayType(date)
+		if dayType != table.expected || special != table.special {
+			t.Errorf(`Wrong day type for %s. Got %v (%v), expected: %v (%v)`, table.date, dayType, special, table.expected, table.special)
+		}
+	}
+}
+
+func TestServiceCalendarHolidays(t *testing.T) {
+	calendars := []FeedCalendar{
+		{6, [7]bool{false, true, true, true, true, true, false}, "20181001", "20181231"},
+		{4, [7]bool{true, false, false, false, false, false, false}, "20181001", "20181231"},
+		{7, [7]bool{false, true, true, true, true, true, false}, "20181001", "20181231"},
+		{5, [7]bool{true, false, false, false, false, false, false}, "20181001", "20181231"},
+	}
+	// feed knows about Christmas for services 6 and 4, but not about 1 November
+	dates := []FeedCalendarDate{
+		{6, "20181225", "1"},
+		{4, "20181225", "2"},
+	}
+	calendar := NewServiceCalendar(calendars, dates, NewHolidayCalendar(nil))
+
+	allSaints, _ := time.Parse(dateLayout, "20181101")
+	if calendar.IsActive(6, allSaints) || !calendar.IsActive(4, allSaints) {
+		t.Error("Expected Sunday service on 1 November")
+	}
+
+	christmas, _ := time.Parse(dateLayout, "20181225")
+	if !calendar.IsActive(6, christmas) || calendar.IsActive(4, christmas) {
+		t.Error("Expected feed's exceptions to take precedence on 25 December")
+	}
+	if calendar.IsActive(7, christmas) || !calendar.IsActive(5, christmas) {
+		t.Error("Expected Sunday service on 25 December for services without exceptions")
+	}
+}
@@ -0,0 +1,363 @@
+package GTFS
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	bolt "github.com/johnnadratowski/golang-neo4j-bolt-driver"
+)
+
+const importBatchSize = 1000
+
+// Nodes of the feed being imported have labels prefixed with "Staged", e.g. StagedStop,
+// so that the served feed stays in place until the new one is complete.
+var feedLabels = []string{
+	"Trip", "StopTime", "Stop", "VehicleType", "RouteType", "Route",
+	"Agency", "FeedInfo", "CalendarDate", "Calendar", "ShapePoint",
+}
+
+var feedLabelPattern = regexp.MustCompile(`:(` + strings.Join(feedLabels, "|") + `)\b`)
+
+// staged rewrites query to use staged labels instead of the served ones
+func staged(query string) string {
+	return feedLabelPattern.ReplaceAllString(query, ":Staged$1")
+}
+
+// ImportFeed replaces contents of the graph with GTFS feed read from zip at given path.
+// The feed is written under staged labels and swapped in only once it's complete, so a failed import
+// leaves the previous feed in place. Returned report contains row counts and malformed rows for every file in the feed.
+func (store *Neo4jStore) ImportFeed(path string) (ImportReport, error) {
+	log.Printf("Reading feed %s", path)
+	feed, err := ReadFeed(path)
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	conn, err := store.driver.OpenNeo(store.url)
+	if err != nil {
+		return feed.Report, err
+	}
+	defer conn.Close()
+
+	log.Print("Removing leftovers of failed imports...")
+	if err := execUntilDone(conn, clearStagedQuery); err != nil {
+		return feed.Report, err
+	}
+
+	log.Print("Creating nodes...")
+	if err := createNodes(conn, feed); err != nil {
+		return feed.Report, err
+	}
+
+	log.Print("Creating indexes...")
+	for _, query := range createIndexesQueries {
+		// staged labels are indexed for creating relationships, served ones for queries after the swap
+		for _, q := range []string{staged(query), query} {
+			if _, err := conn.ExecNeo(q, nil); err != nil {
+				return feed.Report, err
+			}
+		}
+	}
+
+	log.Print("Creating relationships...")
+	if err := createRelationships(conn, feed); err != nil {
+		return feed.Report, err
+	}
+
+	log.Print("Creating walking transfers...")
+	if err := createFootpaths(conn, feed, store.transferRadius); err != nil {
+		return feed.Report, err
+	}
+
+	log.Print("Replacing previous feed...")
+	if err := swapFeed(conn); err != nil {
+		return feed.Report, err
+	}
+
+	finishedAt := time.Now().UnixNano()
+	if _, err := conn.ExecNeo(setImportedAtQuery, map[string]interface{}{"finishedAt": finishedAt}); err != nil {
+		return feed.Report, err
+	}
+
+	store.importMutex.Lock()
+	store.importedAt, store.importCheckedAt = finishedAt, time.Now()
+	store.importMutex.Unlock()
+
+	log.Print("Import finished")
+	return feed.Report, nil
+}
+
+// swapFeed replaces the served feed with the staged one. Import marker goes first,
+// so that servers keep their cached feed until the swap is finished.
+func swapFeed(conn bolt.Conn) error {
+	if _, err := conn.ExecNeo(clearImportedAtQuery, nil); err != nil {
+		return err
+	}
+	if err := execUntilDone(conn, clearGraphQuery); err != nil {
+		return err
+	}
+	for _, label := range feedLabels {
+		if err := execUntilDone(conn, fmt.Sprintf(unstageQuery, label)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execUntilDone runs query returning count of processed nodes until there's none left.
+func execUntilDone(conn bolt.Conn, query string) error {
+	for {
+		rows, err := conn.QueryNeo(query, nil)
+		if err != nil {
+			return err
+		}
+		row, _, err := rows.NextNeo()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		if row[0].(int64) == 0 {
+			return nil
+		}
+	}
+}
+
+// execBatched runs query on staged labels once per batch of rows. rows are available in the query as {rows}.
+func execBatched(conn bolt.Conn, query string, rows []interface{}) error {
+	for start := 0; start < len(rows); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		_, err := conn.ExecNeo(staged(query), map[string]interface{}{"rows": rows[start:end]})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createNodes(conn bolt.Conn, feed *Feed) error {
+	var rows []interface{}
+
+	rows = make([]interface{}, len(feed.Trips))
+	for i, trip := range feed.Trips {
+		rows[i] = map[string]interface{}{
+			"tripID":    trip.TripID,
+			"routeID":   trip.RouteID,
+			"serviceID": trip.ServiceID,
+			"vehicleID": trip.VehicleID,
+			"variantID": trip.VariantID,
+			"headsign":  trip.Headsign,
+			"shapeID":   trip.ShapeID,
+		}
+	}
+	if err := execBatched(conn, createTripsQuery, rows); err != nil {
+		return err
+	}
+
+	rows = make([]interface{}, len(feed.Stops))
+	for i, stop := range feed.Stops {
+		rows[i] = map[string]interface{}{
+			"stopID":    stop.StopID,
+			"code":      stop.Code,
+			"name":      stop.Name,
+			"latitude":  stop.Latitude,
+			"longitude": stop.Longitude,
+		}
+	}
+	if err := execBatched(conn, createStopsQuery, rows); err != nil {
+		return err
+	}
+
+	rows = make([]interface{}, len(feed.StopTimes))
+	for i, st := range feed.StopTimes {
+		rows[i] = map[string]interface{}{
+			"tripID":        st.TripID,
+			"arrivalTime":   st.ArrivalTime,
+			"departureTime": st.DepartureTime,
+			"stopID":        st.StopID,
+			"stopSequence":  st.StopSequence,
+			"onDemand":      st.OnDemand,
+		}
+	}
+	if err := execBatched(conn, createStopTimesQuery, rows); err != nil {
+		return err
+	}
+
+	rows = make([]interface{}, len(feed.VehicleTypes))
+	for i, vt := range feed.VehicleTypes {
+		rows[i] = map[string]interface{}{
+			"vehicleID":   vt.VehicleID,
+			"name":        vt.Name,
+			"description": vt.Description,
+			"symbol":      vt.Symbol,
+		}
+	}
+	if err := execBatched(conn, createVehicleTypesQuery, rows); err != nil {
+		return err
+	}
+
+	rows = make([]interface{}, len(feed.Routes))
+	for i, route := range feed.Routes {
+		rows[i] = map[string]interface{}{
+			"routeID":    route.RouteID,
+			"agencyID":   route.AgencyID,
+			"typeID":     route.TypeID,
+			"validFrom":  route.ValidFrom,
+			"validUntil": route.ValidUntil,
+			"color":      route.Color,
+		}
+	}
+	if err := execBatched(conn, createRoutesQuery, rows); err != nil {
+		return err
+	}
+
+	rows = make([]interface{}, len(feed.RouteTypes))
+	for i, routeType := range feed.RouteTypes {
+		rows[i] = map[string]interface{}{
+			"typeID": routeType.TypeID,
+			"name":   routeType.Name,
+		}
+	}
+	if err := execBatched(conn, createRouteTypesQuery, rows); err != nil {
+		return err
+	}
+
+	rows = make([]interface{}, len(feed.Agencies))
+	for i, agency := range feed.Agencies {
+		rows[i] = map[string]interface{}{
+			"agencyID": agency.AgencyID,
+			"name":     agency.Name,
+			"url":      agency.Url,
+			"phone":    agency.Phone,
+		}
+	}
+	if err := execBatched(conn, createAgenciesQuery, rows); err != nil {
+		return err
+	}
+
+	rows = make([]interface{}, len(feed.FeedInfo))
+	for i, info := range feed.FeedInfo {
+		rows[i] = map[string]interface{}{
+			"publisherName": info.PublisherName,
+			"publisherURL":  info.PublisherURL,
+			"lang":          info.Lang,
+			"startDate":     info.StartDate,
+			"endDate":       info.EndDate,
+			"version":       info.Version,
+		}
+	}
+	if err := execBatched(conn, createFeedInfoQuery, rows); err != nil {
+		return err
+	}
+
+	rows = make([]interface{}, len(feed.Calendars))
+	for i, calendar := range feed.Calendars {
+		rows[i] = map[string]interface{}{
+			"serviceID": calendar.ServiceID,
+			"sunday":    calendar.Weekdays[time.Sunday],
+			"monday":    calendar.Weekdays[time.Monday],
+			"tuesday":   calendar.Weekdays[time.Tuesday],
+			"wednesday": calendar.Weekdays[time.Wednesday],
+			"thursday":  calendar.Weekdays[time.Thursday],
+			"friday":    calendar.Weekdays[time.Friday],
+			"saturday":  calendar.Weekdays[time.Saturday],
+			"startDate": calendar.StartDate,
+			"endDate":   calendar.EndDate,
+		}
+	}
+	if err := execBatched(conn, createCalendarsQuery, rows); err != nil {
+		return err
+	}
+
+	rows = make([]interface{}, len(feed.CalendarDates))
+	for i, date := range feed.CalendarDates {
+		rows[i] = map[string]interface{}{
+			"serviceID":     date.ServiceID,
+			"date":          date.Date,
+			"exceptionType": date.ExceptionType,
+		}
+	}
+	if err := execBatched(conn, createCalendarDatesQuery, rows); err != nil {
+		return err
+	}
+
+	rows = make([]interface{}, len(feed.ShapePoints))
+	for i, point := range feed.ShapePoints {
+		rows[i] = map[string]interface{}{
+			"shapeID":       point.ShapeID,
+			"shapeSequence": point.ShapeSequence,
+			"latitude":      point.Latitude,
+			"longitude":     point.Longitude,
+		}
+	}
+	return execBatched(conn, createShapePointsQuery, rows)
+}
+
+func createRelationships(conn bolt.Conn, feed *Feed) error {
+	// stop times grouped by trip, in order of stop sequence
+	stopTimes := feed.stopTimesByTrip()
+
+	tripBounds := make([]interface{}, 0, len(stopTimes))
+	links := make([]interface{}, 0, len(feed.StopTimes))
+	for tripID, sts := range stopTimes {
+		tripBounds = append(tripBounds, map[string]interface{}{
+			"tripID": tripID,
+			"first":  sts[0].StopSequence,
+			"last":   sts[len(sts)-1].StopSequence,
+		})
+
+		for i := 0; i < len(sts)-1; i++ {
+			links = append(links, map[string]interface{}{
+				"tripID": tripID,
+				"from":   sts[i].StopSequence,
+				"to":     sts[i+1].StopSequence,
+			})
+		}
+	}
+
+	if err := execBatched(conn, createStartsEndsAtQuery, tripBounds); err != nil {
+		return err
+	}
+	if err := execBatched(conn, createNextQuery, links); err != nil {
+		return err
+	}
+
+	tripIDs := make([]interface{}, 0, len(stopTimes))
+	for tripID := range stopTimes {
+		tripIDs = append(tripIDs, tripID)
+	}
+	if err := execBatched(conn, createHappensAtQuery, tripIDs); err != nil {
+		return err
+	}
+
+	_, err := conn.ExecNeo(staged(createIsTypeQuery), nil)
+	return err
+}
+
+// createFootpaths links stops within radius with walk relationships.
+func createFootpaths(conn bolt.Conn, feed *Feed, radius float64) error {
+	stops := make([]Stop, len(feed.Stops))
+	for i, s := range feed.Stops {
+		stops[i] = Stop{s.Name, s.StopID, s.Latitude, s.Longitude, s.Code}
+	}
+
+	var rows []interface{}
+	for stopID, transfers := range newFootpaths(newStopIndex(stops, nil), radius) {
+		for _, transfer := range transfers {
+			rows = append(rows, map[string]interface{}{
+				"from":     stopID,
+				"to":       transfer.ID,
+				"distance": transfer.Distance,
+				"duration": transfer.Duration,
+			})
+		}
+	}
+	log.Printf("Found %d walking transfers", len(rows))
+	return execBatched(conn, createWalkQuery, rows)
+}
@@ -0,0 +1,26 @@
+package GTFS
+
+import (
+	"testing"
+)
+
+func TestStaged(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"MATCH (stop:Stop {stopID: row.stopID})", "MATCH (stop:StagedStop {stopID: row.stopID})"},
+		{"MATCH (st:StopTime {tripID: tripID})", "MATCH (st:StagedStopTime {tripID: tripID})"},
+		{"MATCH (route:Route), (routeType:RouteType {typeID: route.typeID})", "MATCH (route:StagedRoute), (routeType:StagedRouteType {typeID: route.typeID})"},
+		{"CREATE (trip)-[:starts_at]->(first)", "CREATE (trip)-[:starts_at]->(first)"},
+		{"CREATE INDEX ON :CalendarDate(serviceID)", "CREATE INDEX ON :StagedCalendarDate(serviceID)"},
+		{"CALL db.awaitIndexes()", "CALL db.awaitIndexes()"},
+	}
+
+	for _, test := range tests {
+		result := staged(test.query)
+		if result != test.expected {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, test.expected)
+		}
+	}
+}
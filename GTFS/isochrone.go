@@ -0,0 +1,102 @@
+package GTFS
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// number of vertices approximating a walking circle in isochrone's area
+const circleVertices = 16
+
+type IsochroneRequest struct {
+	From     JourneyPlace
+	Time     time.Time
+	Duration time.Duration
+	// whether to include reachable area as GeoJSON
+	Area bool
+}
+
+type ReachableStop struct {
+	Stop
+	Arrival time.Time
+	// in seconds since departure
+	Duration int
+}
+
+type Isochrone struct {
+	Departure time.Time
+	Minutes   int
+	Stops     []ReachableStop
+	// MultiPolygon with walking circles around origin and reached stops
+	Area *GeoJSONFeature `json:",omitempty"`
+}
+
+// Isochrone returns stops reachable from request's origin within its duration, the closest ones first.
+func (planner *JourneyPlanner) Isochrone(request IsochroneRequest) (Isochrone, error) {
+	origins, err := planner.accesses(request.From)
+	if err != nil {
+		return Isochrone{}, err
+	}
+
+	start, end := request.Time, request.Time.Add(request.Duration)
+	scan := planner.scanForward(origins, nil, start, end)
+
+	stops := []ReachableStop{}
+	for idx, arrival := range scan.arrival {
+		if arrival > end.Unix() {
+			continue
+		}
+		stops = append(stops, ReachableStop{
+			Stop:     planner.stops[idx],
+			Arrival:  time.Unix(arrival, 0).In(warsaw),
+			Duration: int(arrival - start.Unix()),
+		})
+	}
+	sort.Slice(stops, func(i, j int) bool {
+		if stops[i].Duration != stops[j].Duration {
+			return stops[i].Duration < stops[j].Duration
+		}
+		return stops[i].ID < stops[j].ID
+	})
+
+	isochrone := Isochrone{Departure: start, Minutes: int(request.Duration / time.Minute), Stops: stops}
+	if request.Area {
+		area := reachableArea(request, stops)
+		isochrone.Area = &area
+	}
+	return isochrone, nil
+}
+
+// reachableArea approximates the area by circles one can walk within the time left at origin and each stop.
+func reachableArea(request IsochroneRequest, stops []ReachableStop) GeoJSONFeature {
+	seconds := request.Duration.Seconds()
+	var polygons [][][][2]float64
+	if request.From.StopID == 0 {
+		polygons = append(polygons, walkingCircle(request.From.Latitude, request.From.Longitude, seconds))
+	}
+	for _, stop := range stops {
+		if left := seconds - float64(stop.Duration); left > 0 {
+			polygons = append(polygons, walkingCircle(stop.Latitude, stop.Longitude, left))
+		}
+	}
+
+	geometry := GeoJSONGeometry{"MultiPolygon", polygons}
+	return newGeoJSONFeature(geometry, map[string]interface{}{"minutes": int(request.Duration / time.Minute)})
+}
+
+// walkingCircle returns polygon around given point with radius walked in given time, up to maxAccessWalk.
+func walkingCircle(latitude, longitude, seconds float64) [][][2]float64 {
+	radius := math.Min(seconds*walkingSpeed, maxAccessWalk)
+	latRadius := radius / earthRadius * 180 / math.Pi
+	lonRadius := latRadius / math.Cos(latitude*math.Pi/180)
+
+	// counterclockwise and closed, as required for exterior rings
+	ring := make([][2]float64, circleVertices+1)
+	for i := 0; i < circleVertices; i++ {
+		angle := 2 * math.Pi * float64(i) / circleVertices
+		ring[i] = geoJSONPosition(latitude+latRadius*math.Sin(angle), longitude+lonRadius*math.Cos(angle))
+	}
+	ring[circleVertices] = ring[0]
+	return [][][2]float64{ring}
+}
@@ -0,0 +1,58 @@
+package GTFS
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIsochrone(t *testing.T) {
+	planner := newTestPlanner()
+	departure := time.Date(2018, 10, 25, 9, 55, 0, 0, warsaw)
+
+	tests := []struct {
+		request  IsochroneRequest
+		expected []string
+	}{
+		{
+			IsochroneRequest{From: JourneyPlace{StopID: 1}, Time: departure, Duration: 20 * time.Minute},
+			[]string{"A 09:55", "B 10:10", "C 10:13"},
+		},
+		{
+			IsochroneRequest{From: JourneyPlace{StopID: 1}, Time: departure, Duration: 40 * time.Minute},
+			[]string{"A 09:55", "B 10:10", "C 10:13", "D 10:30"},
+		},
+		{
+			// walking from coordinates next to A
+			IsochroneRequest{From: JourneyPlace{Latitude: 51.0990, Longitude: 17.0000}, Time: departure, Duration: 10 * time.Minute},
+			[]string{"A 09:56"},
+		},
+	}
+
+	for _, test := range tests {
+		isochrone, err := planner.Isochrone(test.request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result []string
+		for _, stop := range isochrone.Stops {
+			result = append(result, stop.Name+" "+stop.Arrival.Format("15:04"))
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, test.expected)
+		}
+		if isochrone.Area != nil {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, isochrone.Area, nil)
+		}
+	}
+
+	request := tests[2].request
+	request.Area = true
+	isochrone, _ := planner.Isochrone(request)
+	// circles around origin and A
+	polygons := isochrone.Area.Geometry.Coordinates.([][][][2]float64)
+	if len(polygons) != 2 || len(polygons[0][0]) != circleVertices+1 || polygons[0][0][0] != polygons[0][0][circleVertices] {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, polygons, "two closed rings")
+	}
+}
@@ -0,0 +1,656 @@
+package GTFS
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+// ErrNoAccess is returned when there are no stops within walking distance from journey's origin or destination.
+var ErrNoAccess = errors.New("no stops within walking distance")
+
+const (
+	// maximum walk between given coordinates and a stop, in metres
+	maxAccessWalk = 800.0
+	// time needed to change vehicles at the same stop, in seconds
+	minTransferTime = 60
+	// journeys taking longer than that aren't searched for
+	journeyHorizon = 4 * time.Hour
+	// number of journeys returned by default
+	defaultJourneyCount = 3
+)
+
+// JourneyPlace is origin or destination of a journey: a stop if StopID is set, coordinates otherwise.
+type JourneyPlace struct {
+	StopID    int
+	Latitude  float64
+	Longitude float64
+}
+
+type JourneyRequest struct {
+	From JourneyPlace
+	To   JourneyPlace
+	// departure time, or arrival time if ArriveBy is set
+	Time     time.Time
+	ArriveBy bool
+	// number of journeys, zero means default of three
+	Count int
+}
+
+// LegPlace is a point where leg starts, ends or passes through. StopID is zero for coordinates given in request.
+type LegPlace struct {
+	StopID    int
+	Name      string
+	Latitude  float64
+	Longitude float64
+	OnDemand  bool
+}
+
+type JourneyLeg struct {
+	// "walk" or "transit"
+	Mode      string
+	From      LegPlace
+	To        LegPlace
+	Departure time.Time
+	Arrival   time.Time
+	// walking distance in metres
+	Distance float64    `json:",omitempty"`
+	RouteID  string     `json:",omitempty"`
+	Headsign string     `json:",omitempty"`
+	TripID   int        `json:",omitempty"`
+	IsBus    bool       `json:",omitempty"`
+	Stops    []LegPlace `json:",omitempty"`
+}
+
+type Journey struct {
+	Departure time.Time
+	Arrival   time.Time
+	// in seconds
+	Duration  int
+	Transfers int
+	Legs      []JourneyLeg
+}
+
+type plannerTrip struct {
+	TripID    int
+	RouteID   string
+	Headsign  string
+	ServiceID int
+	IsBus     bool
+	ShapeID   int
+	// ordered by stop sequence
+	StopTimes []FeedStopTime
+}
+
+// plannerConnection is a ride between two consecutive stops of a trip.
+type plannerConnection struct {
+	trip int
+	// positions in trip's stop times
+	from int
+	to   int
+	// planner's stop indexes
+	fromStop int
+	toStop   int
+	// seconds from the start of service day
+	departure int
+	arrival   int
+}
+
+type footpath struct {
+	to       int
+	duration int
+	distance float64
+}
+
+// JourneyPlanner finds journeys with Connection Scan Algorithm.
+type JourneyPlanner struct {
+	stops       []Stop
+	stopIndexes map[int]int
+	trips       []plannerTrip
+	// ordered by departure
+	connections []plannerConnection
+	// by stop index
+	footpaths [][]footpath
+	index     *stopIndex
+	calendar  *ServiceCalendar
+}
+
+func newJourneyPlanner(index *stopIndex, trips []plannerTrip, paths footpaths, calendar *ServiceCalendar) *JourneyPlanner {
+	planner := &JourneyPlanner{
+		stops:       index.stops,
+		stopIndexes: map[int]int{},
+		trips:       trips,
+		footpaths:   make([][]footpath, len(index.stops)),
+		index:       index,
+		calendar:    calendar,
+	}
+	for idx, stop := range planner.stops {
+		planner.stopIndexes[stop.ID] = idx
+	}
+
+	for tripIdx, trip := range trips {
+		for i := 0; i+1 < len(trip.StopTimes); i++ {
+			from, to := trip.StopTimes[i], trip.StopTimes[i+1]
+			fromStop, ok := planner.stopIndexes[from.StopID]
+			if !ok {
+				continue
+			}
+			toStop, ok := planner.stopIndexes[to.StopID]
+			if !ok {
+				continue
+			}
+			planner.connections = append(planner.connections, plannerConnection{
+				trip:      tripIdx,
+				from:      i,
+				to:        i + 1,
+				fromStop:  fromStop,
+				toStop:    toStop,
+				departure: newServiceTime(from.DepartureTime).Seconds,
+				arrival:   newServiceTime(to.ArrivalTime).Seconds,
+			})
+		}
+	}
+	sort.SliceStable(planner.connections, func(i, j int) bool {
+		return planner.connections[i].departure < planner.connections[j].departure
+	})
+
+	for idx, stop := range planner.stops {
+		for _, transfer := range paths[stop.ID] {
+			if to, ok := planner.stopIndexes[transfer.ID]; ok {
+				planner.footpaths[idx] = append(planner.footpaths[idx], footpath{to, transfer.Duration, transfer.Distance})
+			}
+		}
+	}
+	return planner
+}
+
+// datedConnection is a connection running on particular service date
+type datedConnection struct {
+	*plannerConnection
+	// trip on the date
+	tripKey   int
+	departure int64
+	arrival   int64
+}
+
+// datedConnections returns connections departing (or arriving, if byArrival is set) between from and to,
+// ordered by departure ascending or arrival descending.
+func (planner *JourneyPlanner) datedConnections(from, to time.Time, byArrival bool) []datedConnection {
+	var dates []time.Time
+	for date := serviceDate(from).AddDate(0, 0, -1); !date.After(serviceDate(to)); date = date.AddDate(0, 0, 1) {
+		dates = append(dates, date)
+	}
+
+	var result []datedConnection
+	for dateIdx, date := range dates {
+		start := ServiceTime{0, date}.Time().Unix()
+		active := map[int]bool{}
+
+		// connections are ordered by departure, which can't be later than arrival
+		last := sort.Search(len(planner.connections), func(i int) bool {
+			return start+int64(planner.connections[i].departure) > to.Unix()
+		})
+		first := 0
+		if !byArrival {
+			first = sort.Search(last, func(i int) bool {
+				return start+int64(planner.connections[i].departure) >= from.Unix()
+			})
+		}
+
+		for idx := first; idx < last; idx++ {
+			c := &planner.connections[idx]
+			departure, arrival := start+int64(c.departure), start+int64(c.arrival)
+			t := departure
+			if byArrival {
+				t = arrival
+			}
+			if t < from.Unix() || t > to.Unix() {
+				continue
+			}
+
+			serviceID := planner.trips[c.trip].ServiceID
+			isActive, ok := active[serviceID]
+			if !ok {
+				isActive = planner.calendar.IsActive(serviceID, date)
+				active[serviceID] = isActive
+			}
+			if isActive {
+				result = append(result, datedConnection{c, c.trip*len(dates) + dateIdx, departure, arrival})
+			}
+		}
+	}
+
+	if byArrival {
+		sort.SliceStable(result, func(i, j int) bool {
+			return result[i].arrival > result[j].arrival
+		})
+	} else {
+		sort.SliceStable(result, func(i, j int) bool {
+			return result[i].departure < result[j].departure
+		})
+	}
+	return result
+}
+
+// access is a walk between journey's origin or destination and a stop
+type access struct {
+	stop     int
+	duration int
+	distance float64
+}
+
+func (planner *JourneyPlanner) accesses(place JourneyPlace) ([]access, error) {
+	if place.StopID != 0 {
+		idx, ok := planner.stopIndexes[place.StopID]
+		if !ok {
+			return nil, ErrStopNotFound
+		}
+		return []access{{idx, 0, 0}}, nil
+	}
+
+	var result []access
+	for _, stop := range planner.index.near(place.Latitude, place.Longitude, maxAccessWalk, 0) {
+		result = append(result, access{planner.stopIndexes[stop.ID], walkingTime(stop.Distance), stop.Distance})
+	}
+	if len(result) == 0 {
+		return nil, ErrNoAccess
+	}
+	return result, nil
+}
+
+const (
+	stepNone = iota
+	stepAccess
+	stepRide
+	stepWalk
+	stepEgress
+)
+
+// journeyStep tells how a stop was reached (or, when searching backwards, how to go on from it)
+type journeyStep struct {
+	kind int
+	// ride: indexes of first and last connection in dated connections
+	enter int
+	exit  int
+	// walk: stop at the other end
+	other    int
+	duration int
+	distance float64
+}
+
+// journeyPart is a step of found journey, in travel order
+type journeyPart struct {
+	kind int
+	// stop indexes, -1 for coordinates from request
+	from int
+	to   int
+	// rides only
+	enter datedConnection
+	exit  datedConnection
+	// walks only
+	duration int
+	distance float64
+}
+
+// forwardScan holds the outcome of scanning connections forward in time
+type forwardScan struct {
+	connections []datedConnection
+	// by stop index
+	arrival []int64
+	via     []journeyStep
+	// best stop to leave the network for one of targets, -1 if none was reached
+	bestStop int
+}
+
+// scanForward finds earliest arrivals at stops when leaving origins at start, using connections departing before end.
+// if targets are given, scanning stops once none of them can be reached earlier.
+func (planner *JourneyPlanner) scanForward(origins, targets []access, start, end time.Time) forwardScan {
+	connections := planner.datedConnections(start, end, false)
+
+	const infinity = math.MaxInt64
+	arrival := make([]int64, len(planner.stops))
+	ready := make([]int64, len(planner.stops))
+	via := make([]journeyStep, len(planner.stops))
+	for idx := range arrival {
+		arrival[idx], ready[idx] = infinity, infinity
+	}
+
+	egress := map[int]access{}
+	for _, target := range targets {
+		egress[target.stop] = target
+	}
+	best, bestStop := int64(infinity), -1
+	reach := func(stop int, t, readyAt int64, step journeyStep) {
+		arrival[stop], ready[stop], via[stop] = t, readyAt, step
+		if target, ok := egress[stop]; ok && t+int64(target.duration) < best {
+			best, bestStop = t+int64(target.duration), stop
+		}
+	}
+	walkFrom := func(stop int) {
+		for _, fp := range planner.footpaths[stop] {
+			if t := arrival[stop] + int64(fp.duration); t < arrival[fp.to] {
+				reach(fp.to, t, t, journeyStep{kind: stepWalk, other: stop, duration: fp.duration, distance: fp.distance})
+			}
+		}
+	}
+
+	for _, origin := range origins {
+		t := start.Unix() + int64(origin.duration)
+		if t < arrival[origin.stop] {
+			reach(origin.stop, t, t, journeyStep{kind: stepAccess, duration: origin.duration, distance: origin.distance})
+		}
+	}
+	for _, origin := range origins {
+		if via[origin.stop].kind == stepAccess {
+			walkFrom(origin.stop)
+		}
+	}
+
+	// key -> trip on a date, value -> index of connection where it was boarded
+	boarded := map[int]int{}
+	for idx, c := range connections {
+		if c.departure >= best {
+			break
+		}
+
+		enter, ok := boarded[c.tripKey]
+		if !ok && ready[c.fromStop] <= c.departure {
+			enter, ok = idx, true
+			boarded[c.tripKey] = idx
+		}
+		if !ok || c.arrival >= arrival[c.toStop] {
+			continue
+		}
+
+		reach(c.toStop, c.arrival, c.arrival+minTransferTime, journeyStep{kind: stepRide, enter: enter, exit: idx})
+		walkFrom(c.toStop)
+	}
+
+	return forwardScan{connections, arrival, via, bestStop}
+}
+
+// earliestArrival finds journey leaving not earlier than start and arriving as early as possible.
+func (planner *JourneyPlanner) earliestArrival(origins, targets []access, start time.Time) []journeyPart {
+	scan := planner.scanForward(origins, targets, start, start.Add(journeyHorizon))
+	connections, via, bestStop := scan.connections, scan.via, scan.bestStop
+	if bestStop < 0 {
+		return nil
+	}
+
+	egress := map[int]access{}
+	for _, target := range targets {
+		egress[target.stop] = target
+	}
+
+	// going back from destination. every step leads to a stop reached earlier, limit guards against cycles anyway.
+	target := egress[bestStop]
+	parts := []journeyPart{{kind: stepEgress, from: bestStop, to: -1, duration: target.duration, distance: target.distance}}
+	stop := bestStop
+	for i := 0; i <= 2*len(planner.stops); i++ {
+		step := via[stop]
+		switch step.kind {
+		case stepRide:
+			enter, exit := connections[step.enter], connections[step.exit]
+			parts = append(parts, journeyPart{kind: stepRide, from: enter.fromStop, to: stop, enter: enter, exit: exit})
+			stop = enter.fromStop
+		case stepWalk:
+			parts = append(parts, journeyPart{kind: stepWalk, from: step.other, to: stop, duration: step.duration, distance: step.distance})
+			stop = step.other
+		case stepAccess:
+			parts = append(parts, journeyPart{kind: stepAccess, from: -1, to: stop, duration: step.duration, distance: step.distance})
+			for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+				parts[i], parts[j] = parts[j], parts[i]
+			}
+			return parts
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// latestDeparture finds journey arriving not later than end and leaving as late as possible.
+func (planner *JourneyPlanner) latestDeparture(origins, targets []access, end time.Time) []journeyPart {
+	connections := planner.datedConnections(end.Add(-journeyHorizon), end, true)
+
+	const minusInfinity = math.MinInt64
+	latest := make([]int64, len(planner.stops))
+	via := make([]journeyStep, len(planner.stops))
+	for idx := range latest {
+		latest[idx] = minusInfinity
+	}
+
+	accesses := map[int]access{}
+	for _, origin := range origins {
+		accesses[origin.stop] = origin
+	}
+	best, bestStop := int64(minusInfinity), -1
+	reach := func(stop int, t int64, step journeyStep) {
+		latest[stop], via[stop] = t, step
+		if origin, ok := accesses[stop]; ok && t-int64(origin.duration) > best {
+			best, bestStop = t-int64(origin.duration), stop
+		}
+	}
+	walkTo := func(stop int) {
+		for _, fp := range planner.footpaths[stop] {
+			if t := latest[stop] - int64(fp.duration); t > latest[fp.to] {
+				reach(fp.to, t, journeyStep{kind: stepWalk, other: stop, duration: fp.duration, distance: fp.distance})
+			}
+		}
+	}
+
+	for _, target := range targets {
+		t := end.Unix() - int64(target.duration)
+		if t > latest[target.stop] {
+			reach(target.stop, t, journeyStep{kind: stepEgress, duration: target.duration, distance: target.distance})
+		}
+	}
+	for _, target := range targets {
+		if via[target.stop].kind == stepEgress {
+			walkTo(target.stop)
+		}
+	}
+
+	// changing vehicles takes some time, walking away or reaching the destination doesn't
+	slack := func(stop int) int64 {
+		if via[stop].kind == stepRide {
+			return minTransferTime
+		}
+		return 0
+	}
+
+	// key -> trip on a date, value -> index of connection where it's left
+	alighted := map[int]int{}
+	for idx, c := range connections {
+		if c.arrival <= best {
+			break
+		}
+
+		exit, ok := alighted[c.tripKey]
+		if !ok && c.arrival+slack(c.toStop) <= latest[c.toStop] {
+			exit, ok = idx, true
+			alighted[c.tripKey] = idx
+		}
+		if !ok || c.departure <= latest[c.fromStop] {
+			continue
+		}
+
+		reach(c.fromStop, c.departure, journeyStep{kind: stepRide, enter: idx, exit: exit})
+		walkTo(c.fromStop)
+	}
+
+	if bestStop < 0 {
+		return nil
+	}
+
+	origin := accesses[bestStop]
+	parts := []journeyPart{{kind: stepAccess, from: -1, to: bestStop, duration: origin.duration, distance: origin.distance}}
+	stop := bestStop
+	for i := 0; i <= 2*len(planner.stops); i++ {
+		step := via[stop]
+		switch step.kind {
+		case stepRide:
+			enter, exit := connections[step.enter], connections[step.exit]
+			parts = append(parts, journeyPart{kind: stepRide, from: stop, to: exit.toStop, enter: enter, exit: exit})
+			stop = exit.toStop
+		case stepWalk:
+			parts = append(parts, journeyPart{kind: stepWalk, from: stop, to: step.other, duration: step.duration, distance: step.distance})
+			stop = step.other
+		case stepEgress:
+			parts = append(parts, journeyPart{kind: stepEgress, from: stop, to: -1, duration: step.duration, distance: step.distance})
+			return parts
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (planner *JourneyPlanner) legPlace(stop int, place JourneyPlace, onDemand bool) LegPlace {
+	if stop < 0 {
+		return LegPlace{Latitude: place.Latitude, Longitude: place.Longitude}
+	}
+	s := planner.stops[stop]
+	return LegPlace{s.ID, s.Name, s.Latitude, s.Longitude, onDemand}
+}
+
+// newJourney turns parts into legs. Walks before the first ride end when it departs, the following ones start
+// when the previous leg arrives. Journeys made on foot only start at anchor.
+func (planner *JourneyPlanner) newJourney(parts []journeyPart, request JourneyRequest, anchor int64) Journey {
+	// walks between the same place are skipped
+	var kept []journeyPart
+	for _, part := range parts {
+		if part.kind == stepRide || part.duration > 0 {
+			kept = append(kept, part)
+		}
+	}
+
+	first := -1
+	for idx, part := range kept {
+		if part.kind == stepRide {
+			first = idx
+			break
+		}
+	}
+
+	times := make([][2]int64, len(kept))
+	t := anchor
+	if first >= 0 {
+		t = kept[first].enter.departure
+		for idx := first - 1; idx >= 0; idx-- {
+			t -= int64(kept[idx].duration)
+		}
+	}
+	for idx, part := range kept {
+		if part.kind == stepRide {
+			times[idx] = [2]int64{part.enter.departure, part.exit.arrival}
+		} else {
+			times[idx] = [2]int64{t, t + int64(part.duration)}
+		}
+		t = times[idx][1]
+	}
+
+	toTime := func(unix int64) time.Time {
+		return time.Unix(unix, 0).In(warsaw)
+	}
+
+	journey := Journey{Legs: []JourneyLeg{}}
+	rides := 0
+	for idx, part := range kept {
+		leg := JourneyLeg{Departure: toTime(times[idx][0]), Arrival: toTime(times[idx][1])}
+		if part.kind == stepRide {
+			rides++
+			trip := planner.trips[part.enter.trip]
+			leg.Mode = "transit"
+			leg.From = planner.legPlace(part.from, request.From, trip.StopTimes[part.enter.from].OnDemand)
+			leg.To = planner.legPlace(part.to, request.To, trip.StopTimes[part.exit.to].OnDemand)
+			leg.RouteID, leg.Headsign, leg.TripID, leg.IsBus = trip.RouteID, trip.Headsign, trip.TripID, trip.IsBus
+			for _, st := range trip.StopTimes[part.enter.to:part.exit.to] {
+				if stop, ok := planner.stopIndexes[st.StopID]; ok {
+					leg.Stops = append(leg.Stops, planner.legPlace(stop, JourneyPlace{}, st.OnDemand))
+				}
+			}
+		} else {
+			leg.Mode = "walk"
+			leg.From = planner.legPlace(part.from, request.From, false)
+			leg.To = planner.legPlace(part.to, request.To, false)
+			leg.Distance = math.Round(part.distance)
+		}
+		journey.Legs = append(journey.Legs, leg)
+	}
+
+	if len(journey.Legs) > 0 {
+		journey.Departure = journey.Legs[0].Departure
+		journey.Arrival = journey.Legs[len(journey.Legs)-1].Arrival
+	} else {
+		journey.Departure, journey.Arrival = toTime(anchor), toTime(anchor)
+	}
+	journey.Duration = int(journey.Arrival.Sub(journey.Departure).Seconds())
+	if rides > 1 {
+		journey.Transfers = rides - 1
+	}
+	return journey
+}
+
+// Plan returns journeys for the request: the earliest arriving ones leaving after request's time,
+// or the latest leaving ones arriving before it.
+func (planner *JourneyPlanner) Plan(request JourneyRequest) ([]Journey, error) {
+	origins, err := planner.accesses(request.From)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := planner.accesses(request.To)
+	if err != nil {
+		return nil, err
+	}
+
+	count := request.Count
+	if count <= 0 {
+		count = defaultJourneyCount
+	}
+
+	journeys := []Journey{}
+	t := request.Time
+	for len(journeys) < count {
+		var journey Journey
+		if request.ArriveBy {
+			parts := planner.latestDeparture(origins, targets, t)
+			if parts == nil {
+				break
+			}
+			journey = planner.newJourney(parts, request, t.Unix()-walkDuration(parts))
+			// next one has to arrive earlier
+			t = journey.Arrival.Add(-time.Minute)
+		} else {
+			parts := planner.earliestArrival(origins, targets, t)
+			if parts == nil {
+				break
+			}
+			journey = planner.newJourney(parts, request, t.Unix())
+			// next one has to leave later
+			t = journey.Departure.Add(time.Minute)
+		}
+
+		journeys = append(journeys, journey)
+		// origin and destination are within walking distance, there's nothing else to look for
+		if len(journey.Legs) == 0 || (len(journey.Legs) == 1 && journey.Legs[0].Mode == "walk") {
+			break
+		}
+	}
+
+	if request.ArriveBy {
+		for i, j := 0, len(journeys)-1; i < j; i, j = i+1, j-1 {
+			journeys[i], journeys[j] = journeys[j], journeys[i]
+		}
+	}
+	return journeys, nil
+}
+
+func walkDuration(parts []journeyPart) int64 {
+	var total int64
+	for _, part := range parts {
+		total += int64(part.duration)
+	}
+	return total
+}
@@ -0,0 +1,103 @@
+package GTFS
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestPlanner() *JourneyPlanner {
+	stops := []Stop{
+		{"A", 1, 51.1000, 17.0000, 0},
+		{"B", 2, 51.1100, 17.0000, 0},
+		// 200 m from B
+		{"C", 3, 51.1118, 17.0000, 0},
+		{"D", 4, 51.1300, 17.0000, 0},
+	}
+	stopTimes := func(tripID int, times ...interface{}) []FeedStopTime {
+		var result []FeedStopTime
+		for i := 0; i < len(times); i += 2 {
+			result = append(result, FeedStopTime{TripID: tripID, StopID: times[i].(int), ArrivalTime: times[i+1].(string), DepartureTime: times[i+1].(string), StopSequence: i / 2})
+		}
+		return result
+	}
+	trips := []plannerTrip{
+		{1, "1", "B", 1, false, 0, stopTimes(1, 1, "10:00", 2, "10:10")},
+		{2, "2", "D", 1, true, 0, stopTimes(2, 3, "10:15", 4, "10:30")},
+		{3, "3", "D", 1, true, 0, stopTimes(3, 1, "10:05", 2, "10:20", 4, "11:00")},
+	}
+	calendar := NewServiceCalendar([]FeedCalendar{
+		{ServiceID: 1, Weekdays: [7]bool{true, true, true, true, true, true, true}, StartDate: "20181001", EndDate: "20181231"},
+	}, nil, nil)
+	index := newStopIndex(stops, nil)
+	return newJourneyPlanner(index, trips, newFootpaths(index, DefaultTransferRadius), calendar)
+}
+
+// describe lists legs as "mode:route:from-to:departure-arrival"
+func describe(journey Journey) []string {
+	var result []string
+	for _, leg := range journey.Legs {
+		result = append(result, leg.Mode+":"+leg.RouteID+":"+leg.From.Name+"-"+leg.To.Name+":"+
+			leg.Departure.Format("15:04")+"-"+leg.Arrival.Format("15:04"))
+	}
+	return result
+}
+
+func TestPlanJourneys(t *testing.T) {
+	planner := newTestPlanner()
+
+	tests := []struct {
+		request  JourneyRequest
+		expected [][]string
+	}{
+		{
+			JourneyRequest{From: JourneyPlace{StopID: 1}, To: JourneyPlace{StopID: 4}, Time: time.Date(2018, 10, 25, 9, 55, 0, 0, warsaw), Count: 2},
+			[][]string{
+				{"transit:1:A-B:10:00-10:10", "walk::B-C:10:10-10:13", "transit:2:C-D:10:15-10:30"},
+				{"transit:3:A-D:10:05-11:00"},
+			},
+		},
+		{
+			JourneyRequest{From: JourneyPlace{StopID: 1}, To: JourneyPlace{StopID: 4}, Time: time.Date(2018, 10, 25, 10, 45, 0, 0, warsaw), ArriveBy: true, Count: 1},
+			[][]string{
+				{"transit:1:A-B:10:00-10:10", "walk::B-C:10:10-10:13", "transit:2:C-D:10:15-10:30"},
+			},
+		},
+		{
+			// walking from coordinates next to A
+			JourneyRequest{From: JourneyPlace{Latitude: 51.0990, Longitude: 17.0000}, To: JourneyPlace{StopID: 2}, Time: time.Date(2018, 10, 25, 9, 55, 0, 0, warsaw), Count: 1},
+			[][]string{
+				{"walk::-A:09:58-10:00", "transit:1:A-B:10:00-10:10"},
+			},
+		},
+		{
+			// nothing runs that late
+			JourneyRequest{From: JourneyPlace{StopID: 1}, To: JourneyPlace{StopID: 4}, Time: time.Date(2018, 10, 25, 12, 0, 0, 0, warsaw)},
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		journeys, err := planner.Plan(test.request)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result [][]string
+		for _, journey := range journeys {
+			result = append(result, describe(journey))
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, test.expected)
+		}
+	}
+
+	journeys, _ := planner.Plan(tests[0].request)
+	if journeys[0].Transfers != 1 || journeys[0].Duration != 30*60 {
+		t.Errorf("Wrong journey: %+v", journeys[0])
+	}
+
+	if _, err := planner.Plan(JourneyRequest{From: JourneyPlace{StopID: 42}, To: JourneyPlace{StopID: 4}}); err != ErrStopNotFound {
+		t.Errorf("Wrong error for unknown stop: %v", err)
+	}
+}
@@ -0,0 +1,595 @@
+package GTFS
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type memoryTrip struct {
+	FeedTrip
+	StopTimes []FeedStopTime
+}
+
+type memoryStopTime struct {
+	FeedStopTime
+	Trip *memoryTrip
+}
+
+// MemoryStore is a Store that keeps whole GTFS feed in memory.
+// Dataset for one city is small enough for that, so API can be run without any database server.
+type MemoryStore struct {
+	stops       []Stop
+	stopsByID   map[int]Stop
+	stopsByName map[string][]Stop
+	stopsByCode map[int]Stop
+	index       *stopIndex
+
+	routes       map[string]FeedRoute
+	routeTypes   map[int]FeedRouteType
+	agencies     map[int]FeedAgency
+	tripsByID    map[int]*memoryTrip
+	tripsByRoute map[string][]*memoryTrip
+
+	// stop times happening at given stop, ordered by departure time
+	stopTimesByStop map[int][]memoryStopTime
+
+	// shape points ordered by sequence
+	shapes map[int]ShapePoints
+
+	calendar  *ServiceCalendar
+	footpaths footpaths
+	planner   *JourneyPlanner
+	vehicles  *vehicleSchedule
+	version   string
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// OpenFeed reads GTFS zip at given path into memory.
+// Walking transfers are found between stops within transferRadius metres.
+func OpenFeed(path string, holidays *HolidayCalendar, transferRadius float64) (*MemoryStore, error) {
+	log.Printf("Reading feed %s", path)
+	feed, err := ReadFeed(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Feed read:\n%s", feed.Report)
+
+	return NewMemoryStore(feed, holidays, transferRadius), nil
+}
+
+func NewMemoryStore(feed *Feed, holidays *HolidayCalendar, transferRadius float64) *MemoryStore {
+	store := &MemoryStore{
+		stopsByID:       map[int]Stop{},
+		stopsByName:     map[string][]Stop{},
+		stopsByCode:     map[int]Stop{},
+		routes:          map[string]FeedRoute{},
+		routeTypes:      map[int]FeedRouteType{},
+		agencies:        map[int]FeedAgency{},
+		tripsByID:       map[int]*memoryTrip{},
+		tripsByRoute:    map[string][]*memoryTrip{},
+		stopTimesByStop: map[int][]memoryStopTime{},
+		shapes:          map[int]ShapePoints{},
+		calendar:        NewServiceCalendar(feed.Calendars, feed.CalendarDates, holidays),
+		version:         feedVersion(feed.FeedInfo),
+	}
+
+	for _, s := range feed.Stops {
+		stop := Stop{s.Name, s.StopID, s.Latitude, s.Longitude, s.Code}
+		store.stops = append(store.stops, stop)
+		store.stopsByID[stop.ID] = stop
+		store.stopsByName[stop.Name] = append(store.stopsByName[stop.Name], stop)
+		// codes should be unique, if they aren't the stop with lowest ID wins
+		if other, ok := store.stopsByCode[stop.Code]; !ok || stop.ID < other.ID {
+			store.stopsByCode[stop.Code] = stop
+		}
+	}
+	sort.SliceStable(store.stops, func(i, j int) bool {
+		return store.stops[i].Name < store.stops[j].Name
+	})
+
+	for _, route := range feed.Routes {
+		store.routes[route.RouteID] = route
+	}
+	for _, routeType := range feed.RouteTypes {
+		store.routeTypes[routeType.TypeID] = routeType
+	}
+	for _, agency := range feed.Agencies {
+		store.agencies[agency.AgencyID] = agency
+	}
+
+	stopTimes := feed.stopTimesByTrip()
+	for _, t := range feed.Trips {
+		sts, ok := stopTimes[t.TripID]
+		if !ok {
+			continue
+		}
+
+		trip := &memoryTrip{t, sts}
+		store.tripsByID[trip.TripID] = trip
+		store.tripsByRoute[trip.RouteID] = append(store.tripsByRoute[trip.RouteID], trip)
+		for _, st := range sts {
+			store.stopTimesByStop[st.StopID] = append(store.stopTimesByStop[st.StopID], memoryStopTime{st, trip})
+		}
+	}
+	routes := map[int][]string{}
+	for stopID, sts := range store.stopTimesByStop {
+		sort.SliceStable(sts, func(i, j int) bool {
+			return sts[i].DepartureTime < sts[j].DepartureTime
+		})
+
+		seen := map[string]bool{}
+		for _, st := range sts {
+			if !seen[st.Trip.RouteID] {
+				seen[st.Trip.RouteID] = true
+				routes[stopID] = append(routes[stopID], st.Trip.RouteID)
+			}
+		}
+	}
+	store.index = newStopIndex(store.stops, routes)
+	store.footpaths = newFootpaths(store.index, transferRadius)
+
+	trips := make([]plannerTrip, 0, len(store.tripsByID))
+	for _, trip := range store.tripsByID {
+		trips = append(trips, plannerTrip{trip.TripID, trip.RouteID, trip.Headsign, trip.ServiceID, store.isBus(trip.RouteID), trip.ShapeID, trip.StopTimes})
+	}
+	sort.Slice(trips, func(i, j int) bool {
+		return trips[i].TripID < trips[j].TripID
+	})
+	store.planner = newJourneyPlanner(store.index, trips, store.footpaths, store.calendar)
+
+	for _, p := range feed.ShapePoints {
+		point := ShapePoint{p.ShapeID, p.ShapeSequence, float32(p.Latitude), float32(p.Longitude)}
+		store.shapes[p.ShapeID] = append(store.shapes[p.ShapeID], point)
+	}
+	for _, points := range store.shapes {
+		sort.Slice(points, func(i, j int) bool {
+			return points[i].ShapeSequence < points[j].ShapeSequence
+		})
+	}
+	store.vehicles = newVehicleSchedule(store.planner, store.shapes)
+
+	log.Printf("Loaded %d stops, %d routes and %d trips into memory", len(store.stops), len(store.routes), len(store.tripsByID))
+	return store
+}
+
+func (store *MemoryStore) routeType(routeID string) string {
+	return store.routeTypes[store.routes[routeID].TypeID].Name
+}
+
+func (store *MemoryStore) isBus(routeID string) bool {
+	return strings.Contains(store.routeType(routeID), "bus")
+}
+
+func (store *MemoryStore) firstStop(trip *memoryTrip) Stop {
+	return store.stopsByID[trip.StopTimes[0].StopID]
+}
+
+func (store *MemoryStore) lastStop(trip *memoryTrip) Stop {
+	return store.stopsByID[trip.StopTimes[len(trip.StopTimes)-1].StopID]
+}
+
+// stopTimesAt returns stop times happening at any of the stops with given name
+func (store *MemoryStore) stopTimesAt(stopName string) []memoryStopTime {
+	var stopTimes []memoryStopTime
+	for _, stop := range store.stopsByName[stopName] {
+		stopTimes = append(stopTimes, store.stopTimesByStop[stop.ID]...)
+	}
+	return stopTimes
+}
+
+func (store *MemoryStore) GetAllStops() ([]Stop, error) {
+	stops := make([]Stop, len(store.stops))
+	copy(stops, store.stops)
+	return stops, nil
+}
+
+func (store *MemoryStore) GetStopByID(stopID int) (Stop, error) {
+	stop, ok := store.stopsByID[stopID]
+	if !ok {
+		return Stop{}, ErrStopNotFound
+	}
+	return stop, nil
+}
+
+func (store *MemoryStore) GetStopByCode(code int) (Stop, error) {
+	stop, ok := store.stopsByCode[code]
+	if !ok {
+		return Stop{}, ErrStopNotFound
+	}
+	return stop, nil
+}
+
+func (store *MemoryStore) GetStations() ([]Station, error) {
+	return store.index.stations, nil
+}
+
+func (store *MemoryStore) GetStation(stationID int) (Station, error) {
+	return findStation(store.index.stations, stationID)
+}
+
+func (store *MemoryStore) GetStopsNear(lat, lon, radius float64, limit int) ([]NearbyStop, error) {
+	return store.index.near(lat, lon, radius, limit), nil
+}
+
+func (store *MemoryStore) SearchStops(query string, limit int) ([]StopSearchResult, error) {
+	return store.index.search(query, limit), nil
+}
+
+func (store *MemoryStore) GetTransfers(stopID int) ([]Transfer, error) {
+	if _, ok := store.stopsByID[stopID]; !ok {
+		return nil, ErrStopNotFound
+	}
+	return store.footpaths.transfersFrom(stopID), nil
+}
+
+func (store *MemoryStore) GetAllRouteIDs() ([]Route, error) {
+	routes := make([]Route, 0, len(store.tripsByRoute))
+	for routeID := range store.tripsByRoute {
+		if _, ok := store.routes[routeID]; !ok {
+			continue
+		}
+		routes = append(routes, Route{routeID, store.isBus(routeID)})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].ID < routes[j].ID
+	})
+	return routes, nil
+}
+
+// routeVariants groups trips by route and first and last stop names
+func (store *MemoryStore) routeVariants(trips []*memoryTrip) []RouteVariant {
+	type key struct {
+		routeID   string
+		firstStop string
+		lastStop  string
+	}
+
+	variants := map[key]*RouteVariant{}
+	var keys []key
+	for _, trip := range trips {
+		k := key{trip.RouteID, store.firstStop(trip).Name, store.lastStop(trip).Name}
+		variant, ok := variants[k]
+		if !ok {
+			variant = &RouteVariant{k.routeID, store.isBus(k.routeID), k.firstStop, k.lastStop, nil}
+			variants[k] = variant
+			keys = append(keys, k)
+		}
+		variant.TripIDs = append(variant.TripIDs, trip.TripID)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].routeID != keys[j].routeID {
+			return keys[i].routeID < keys[j].routeID
+		}
+		if keys[i].firstStop != keys[j].firstStop {
+			return keys[i].firstStop < keys[j].firstStop
+		}
+		return keys[i].lastStop < keys[j].lastStop
+	})
+
+	result := make([]RouteVariant, len(keys))
+	for i, k := range keys {
+		result[i] = *variants[k]
+	}
+	return result
+}
+
+func (store *MemoryStore) GetRouteVariants(routeID string) ([]RouteVariant, error) {
+	return store.routeVariants(store.tripsByRoute[routeID]), nil
+}
+
+// tripsOf returns distinct trips of given stop times
+func tripsOf(stopTimes []memoryStopTime) []*memoryTrip {
+	seen := map[int]bool{}
+	var trips []*memoryTrip
+	for _, st := range stopTimes {
+		if !seen[st.Trip.TripID] {
+			seen[st.Trip.TripID] = true
+			trips = append(trips, st.Trip)
+		}
+	}
+	return trips
+}
+
+func (store *MemoryStore) GetRouteVariantsByStopName(stopName string) ([]RouteVariant, error) {
+	return store.routeVariants(tripsOf(store.stopTimesAt(stopName))), nil
+}
+
+func (store *MemoryStore) GetRouteVariantsByStopID(stopID int) ([]RouteVariant, error) {
+	if _, err := store.GetStopByID(stopID); err != nil {
+		return nil, err
+	}
+	return store.routeVariants(tripsOf(store.stopTimesByStop[stopID])), nil
+}
+
+// tripsTowards returns IDs of route's trips which end at stop named direction
+func (store *MemoryStore) tripsTowards(routeID, direction string) map[int]bool {
+	tripIDs := map[int]bool{}
+	for _, trip := range store.tripsByRoute[routeID] {
+		if store.lastStop(trip).Name == direction {
+			tripIDs[trip.TripID] = true
+		}
+	}
+	return tripIDs
+}
+
+func (store *MemoryStore) timetableRows(routeID, stopName, direction string) []timeTableRow {
+	var rows []timeTableRow
+	tripIDs := store.tripsTowards(routeID, direction)
+	for _, st := range store.stopTimesAt(stopName) {
+		if tripIDs[st.TripID] {
+			rows = append(rows, timeTableRow{TimeTableEntry{st.TripID, newServiceTime(st.ArrivalTime), newServiceTime(st.DepartureTime)}, st.Trip.ServiceID, st.StopID})
+		}
+	}
+	return rows
+}
+
+func (store *MemoryStore) GetTimetable(routeID, stopName, direction string) (TimeTable, error) {
+	rows := store.timetableRows(routeID, stopName, direction)
+	return newTimeTable(routeID, stopName, direction, rows, store.calendar), nil
+}
+
+func (store *MemoryStore) GetTimetableForDate(routeID, stopName, direction string, date time.Time) (DateTimeTable, error) {
+	rows := store.timetableRows(routeID, stopName, direction)
+	return newDateTimeTable(routeID, stopName, direction, date, rows, store.calendar), nil
+}
+
+func (store *MemoryStore) GetTimetableAtStop(routeID string, stopID int, direction string) (TimeTable, error) {
+	stop, err := store.GetStopByID(stopID)
+	if err != nil {
+		return TimeTable{}, err
+	}
+
+	rows := rowsAtStop(store.timetableRows(routeID, stop.Name, direction), stopID)
+	return newTimeTable(routeID, stop.Name, direction, rows, store.calendar), nil
+}
+
+func (store *MemoryStore) GetTimetableAtStopForDate(routeID string, stopID int, direction string, date time.Time) (DateTimeTable, error) {
+	stop, err := store.GetStopByID(stopID)
+	if err != nil {
+		return DateTimeTable{}, err
+	}
+
+	rows := rowsAtStop(store.timetableRows(routeID, stop.Name, direction), stopID)
+	return newDateTimeTable(routeID, stop.Name, direction, date, rows, store.calendar), nil
+}
+
+func (store *MemoryStore) GetRouteInfo(routeID string) (RouteInfo, error) {
+	route, ok := store.routes[routeID]
+	if !ok {
+		return RouteInfo{}, nil
+	}
+
+	agency := store.agencies[route.AgencyID]
+	routeType := store.routeType(routeID)
+	isBus := strings.Contains(routeType, "bus")
+	color := routeColor(route.Color, routeType)
+	return RouteInfo{routeID, routeType, isBus, route.ValidFrom, route.ValidUntil, agency.Name, agency.Url, agency.Phone, color}, nil
+}
+
+// headsignsByPopularity returns headsigns of given trips, most common first
+func headsignsByPopularity(trips []*memoryTrip) []string {
+	counts := map[string]int{}
+	var headsigns []string
+	for _, trip := range trips {
+		if counts[trip.Headsign] == 0 {
+			headsigns = append(headsigns, trip.Headsign)
+		}
+		counts[trip.Headsign]++
+	}
+
+	sort.SliceStable(headsigns, func(i, j int) bool {
+		return counts[headsigns[i]] > counts[headsigns[j]]
+	})
+	return headsigns
+}
+
+func (store *MemoryStore) GetRouteDirections(routeID string) (RouteDirections, error) {
+	return RouteDirections{routeID, headsignsByPopularity(store.tripsByRoute[routeID])}, nil
+}
+
+func (store *MemoryStore) GetRouteDirectionsThroughStop(routeID, stopName string) (RouteDirections, error) {
+	seen := map[int]bool{}
+	var trips []*memoryTrip
+	for _, st := range store.stopTimesAt(stopName) {
+		if st.Trip.RouteID == routeID && !seen[st.TripID] {
+			seen[st.TripID] = true
+			trips = append(trips, st.Trip)
+		}
+	}
+	return RouteDirections{routeID, headsignsByPopularity(trips)}, nil
+}
+
+func (store *MemoryStore) GetTripTimeline(tripID int, date time.Time) (TripTimeline, error) {
+	timeline := TripTimeline{TripID: tripID}
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	trip, ok := store.tripsByID[tripID]
+	if !ok {
+		return timeline, nil
+	}
+
+	for _, st := range trip.StopTimes {
+		stopName := store.stopsByID[st.StopID].Name
+		timeline.Timeline = append(timeline.Timeline, TripTimelineEntry{
+			StopName:      stopName,
+			DepartureTime: newServiceTime(st.DepartureTime).On(date),
+			OnDemand:      st.OnDemand,
+			StopID:        st.StopID,
+			StopSequence:  st.StopSequence,
+		})
+	}
+	return timeline, nil
+}
+
+func (store *MemoryStore) GetStopsForRouteID(routeID string) (StopsForRoute, error) {
+	data := StopsForRoute{RouteID: routeID}
+
+	counts := map[string]int{}
+	for _, trip := range store.tripsByRoute[routeID] {
+		for _, st := range trip.StopTimes {
+			name := store.stopsByID[st.StopID].Name
+			if counts[name] == 0 {
+				data.StopNames = append(data.StopNames, name)
+			}
+			counts[name]++
+		}
+	}
+
+	sort.SliceStable(data.StopNames, func(i, j int) bool {
+		return counts[data.StopNames[i]] > counts[data.StopNames[j]]
+	})
+	return data, nil
+}
+
+func (store *MemoryStore) stopsForTrip(trip *memoryTrip) []StopOnDemand {
+	stops := make([]StopOnDemand, len(trip.StopTimes))
+	for i, st := range trip.StopTimes {
+		stops[i] = StopOnDemand{store.stopsByID[st.StopID], st.OnDemand}
+	}
+	return stops
+}
+
+func (store *MemoryStore) GetMapData(routeID, direction, stopName string) (MapData, error) {
+	data := MapData{RouteID: routeID}
+
+	// canonical trip for each shape
+	tripIDs := store.tripsTowards(routeID, direction)
+	canonicalTrips := map[int]*memoryTrip{}
+	var shapeIDs []int
+	for _, st := range store.stopTimesAt(stopName) {
+		if !tripIDs[st.TripID] {
+			continue
+		}
+		if _, ok := canonicalTrips[st.Trip.ShapeID]; !ok {
+			canonicalTrips[st.Trip.ShapeID] = st.Trip
+			shapeIDs = append(shapeIDs, st.Trip.ShapeID)
+		}
+	}
+
+	trips := make([][]StopOnDemand, 0, len(shapeIDs))
+	for _, shapeID := range shapeIDs {
+		data.Shapes = append(data.Shapes, Shape{ShapeID: shapeID, Points: store.shapes[shapeID]})
+		trips = append(trips, store.stopsForTrip(canonicalTrips[shapeID]))
+	}
+	data.Stops = mapStops(trips)
+
+	return data, nil
+}
+
+func (store *MemoryStore) GetMapDataForTripID(tripID int) (MapData, error) {
+	var data MapData
+
+	trip, ok := store.tripsByID[tripID]
+	if !ok || len(store.shapes[trip.ShapeID]) == 0 {
+		return data, nil
+	}
+
+	data.RouteID = trip.RouteID
+	data.Shapes = append(data.Shapes, Shape{ShapeID: trip.ShapeID, Points: store.shapes[trip.ShapeID]})
+	data.Stops = mapStops([][]StopOnDemand{store.stopsForTrip(trip)})
+	return data, nil
+}
+
+func (store *MemoryStore) upcomingDepartures(stopNames []string, query DepartureQuery) []UpcomingDeparture {
+	var departures []UpcomingDeparture
+	for _, stopName := range uniqueStopNames(stopNames) {
+		stopTimes := store.stopTimesAt(stopName)
+		data := make([]UpcomingDeparture, len(stopTimes))
+		for i, st := range stopTimes {
+			data[i] = UpcomingDeparture{
+				Stop:          store.stopsByID[st.StopID],
+				TripID:        st.TripID,
+				DepartureTime: newServiceTime(st.DepartureTime),
+				OnDemand:      st.OnDemand,
+				RouteID:       st.Trip.RouteID,
+				IsBus:         store.isBus(st.Trip.RouteID),
+				Direction:     st.Trip.Headsign,
+				ServiceID:     st.Trip.ServiceID,
+				StopSequence:  st.StopSequence,
+			}
+		}
+		departures = append(departures, filterDepartures(data, store.calendar, query)...)
+	}
+	return departures
+}
+
+func (store *MemoryStore) GetUpcomingDepartures(stopNames []string, query DepartureQuery) ([]UpcomingDepartures, error) {
+	return groupDepartures(store.upcomingDepartures(stopNames, query), query.limit()), nil
+}
+
+func (store *MemoryStore) PlanJourneys(request JourneyRequest) ([]Journey, error) {
+	return store.planner.Plan(request)
+}
+
+func (store *MemoryStore) GetIsochrone(request IsochroneRequest) (Isochrone, error) {
+	return store.planner.Isochrone(request)
+}
+
+func (store *MemoryStore) GetScheduledVehicles(query VehicleQuery) ([]ScheduledVehicle, error) {
+	return store.vehicles.vehicles(query), nil
+}
+
+func (store *MemoryStore) GetTrip(tripID int) (Trip, error) {
+	trip, ok := store.tripsByID[tripID]
+	if !ok {
+		return Trip{}, ErrTripNotFound
+	}
+	return Trip{trip.TripID, trip.RouteID, trip.Headsign, store.isBus(trip.RouteID)}, nil
+}
+
+func (store *MemoryStore) GetFeedVersion() (string, error) {
+	return store.version, nil
+}
+
+func (store *MemoryStore) GetAgencyIDs() ([]string, error) {
+	agencyIDs := make([]int, 0, len(store.agencies))
+	for agencyID := range store.agencies {
+		agencyIDs = append(agencyIDs, agencyID)
+	}
+	sort.Ints(agencyIDs)
+
+	result := make([]string, len(agencyIDs))
+	for i, agencyID := range agencyIDs {
+		result[i] = strconv.Itoa(agencyID)
+	}
+	return result, nil
+}
+
+func (store *MemoryStore) GetNetwork() (Network, error) {
+	routeIDs := make([]string, 0, len(store.tripsByRoute))
+	for routeID := range store.tripsByRoute {
+		routeIDs = append(routeIDs, routeID)
+	}
+	sort.Strings(routeIDs)
+
+	var network Network
+	for _, routeID := range routeIDs {
+		shapeIDs := map[int]bool{}
+		for _, trip := range store.tripsByRoute[routeID] {
+			shapeIDs[trip.ShapeID] = true
+		}
+		var sortedShapeIDs []int
+		for shapeID := range shapeIDs {
+			sortedShapeIDs = append(sortedShapeIDs, shapeID)
+		}
+		sort.Ints(sortedShapeIDs)
+
+		color := routeColor(store.routes[routeID].Color, store.routeType(routeID))
+		for _, shapeID := range sortedShapeIDs {
+			network.Shapes = append(network.Shapes, NetworkShape{shapeID, routeID, store.isBus(routeID), color, store.shapes[shapeID]})
+		}
+	}
+	network.Stops = newNetworkStops(store.stops, store.index.routes)
+	return network, nil
+}
+
+func (store *MemoryStore) GetDepartureBoard(stopNames []string, query DepartureQuery) (DepartureBoard, error) {
+	return newDepartureBoard(store.upcomingDepartures(stopNames, query), query.limit()), nil
+}
@@ -0,0 +1,246 @@
+package GTFS
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestMemoryStore(t *testing.T) *MemoryStore {
+	files := map[string]string{
+		"route_types.txt": "route_type2_id,route_type2_name\n" +
+			"31,Normalna tramwajowa\n" +
+			"35,Normalna autobusowa\n",
+		"shapes.txt": "shape_id,shape_pt_lat,shape_pt_lon,shape_pt_sequence\n" +
+			"100,51.13,17.00,1\n" +
+			"100,51.11,17.06,0\n",
+		"calendar.txt": "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\n" +
+			"6,1,1,1,1,0,0,0,20181001,20181231\n" +
+			"8,0,0,0,0,1,0,0,20181001,20181231\n" +
+			"3,0,0,0,0,0,1,0,20181001,20181231\n" +
+			"4,0,0,0,0,0,0,1,20181001,20181231\n",
+	}
+	for name, content := range minimalFeed {
+		files[name] = content
+	}
+	files["trips.txt"] = "route_id,service_id,trip_id,trip_headsign,shape_id,variant_id,vehicle_id\n" +
+		"33,6,6_1,PILCZYCE,100,9,3\n" +
+		"33,3,3_2,PILCZYCE,100,9,3\n" +
+		"33,4,4_3,PLAC GRUNWALDZKI,101,9,3\n" +
+		"33,8,9_4,PILCZYCE,100,9,3\n"
+	files["stops.txt"] = "stop_id,stop_code,stop_name,stop_lat,stop_lon\n" +
+		"1,20001,Plac Grunwaldzki,51.11,17.06\n" +
+		"2,20002,Pilczyce,51.13,17.00\n" +
+		"3,20003,Plac Grunwaldzki,51.12,17.06\n"
+	files["stop_times.txt"] = "trip_id,arrival_time,departure_time,stop_id,stop_sequence,pickup_type,drop_off_type\n" +
+		"6_1,25:10:00,25:10:00,1,0,0,0\n" +
+		"6_1,25:20:00,25:21:00,2,1,0,3\n" +
+		"3_2,10:00:00,10:00:00,1,0,0,0\n" +
+		"3_2,10:10:00,10:10:00,2,1,0,3\n" +
+		"4_3,11:00:00,11:00:00,2,0,0,0\n" +
+		"4_3,11:10:00,11:10:00,3,1,0,0\n" +
+		"9_4,12:00:00,12:00:00,1,0,0,0\n" +
+		"9_4,12:10:00,12:10:00,2,1,0,3\n"
+
+	feed, err := readFeed(makeZip(t, files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewMemoryStore(feed, NewHolidayCalendar(nil), DefaultTransferRadius)
+}
+
+func TestMemoryStoreTimetable(t *testing.T) {
+	store := newTestMemoryStore(t)
+
+	tt, err := store.GetTimetable("33", "Plac Grunwaldzki", "Pilczyce")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(tt.Weekdays, []TimeTableEntry{{61, ServiceTime{Seconds: 25*3600 + 600}, ServiceTime{Seconds: 25*3600 + 600}}}) {
+		t.Errorf("Wrong weekdays: %v", tt.Weekdays)
+	}
+	if !reflect.DeepEqual(tt.Saturdays, []TimeTableEntry{{32, ServiceTime{Seconds: 10 * 3600}, ServiceTime{Seconds: 10 * 3600}}}) {
+		t.Errorf("Wrong saturdays: %v", tt.Saturdays)
+	}
+	if len(tt.Sundays) != 0 {
+		t.Errorf("Wrong sundays: %v", tt.Sundays)
+	}
+}
+
+func TestMemoryStoreRouteVariants(t *testing.T) {
+	store := newTestMemoryStore(t)
+
+	variants, err := store.GetRouteVariants("33")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []RouteVariant{
+		{"33", false, "Pilczyce", "Plac Grunwaldzki", []int{43}},
+		{"33", false, "Plac Grunwaldzki", "Pilczyce", []int{61, 32, 94}},
+	}
+	if !reflect.DeepEqual(variants, expected) {
+		t.Errorf(`Wrong variants. Got "%v", expected: "%v"`, variants, expected)
+	}
+
+	directions, _ := store.GetRouteDirections("33")
+	if !reflect.DeepEqual(directions.Directions, []string{"PILCZYCE", "PLAC GRUNWALDZKI"}) {
+		t.Errorf("Wrong directions: %v", directions.Directions)
+	}
+}
+
+func TestMemoryStoreTripMap(t *testing.T) {
+	store := newTestMemoryStore(t)
+
+	data, err := store.GetMapDataForTripID(61)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Shapes) != 1 || len(data.Shapes[0].Points) != 2 || data.Shapes[0].Points[0].ShapeSequence != 0 {
+		t.Errorf("Wrong shapes: %v", data.Shapes)
+	}
+	if len(data.Stops) != 2 {
+		t.Errorf("Wrong stops: %v", data.Stops)
+	}
+
+	date := time.Date(2018, 5, 11, 0, 0, 0, 0, warsaw)
+	timeline, _ := store.GetTripTimeline(61, date)
+	expected := []TripTimelineEntry{
+		{StopName: "Plac Grunwaldzki", DepartureTime: ServiceTime{25*3600 + 600, date}, StopID: 1},
+		{StopName: "Pilczyce", DepartureTime: ServiceTime{25*3600 + 1260, date}, OnDemand: true, StopID: 2, StopSequence: 1},
+	}
+	if !reflect.DeepEqual(timeline.Timeline, expected) {
+		t.Errorf("Wrong timeline: %v", timeline.Timeline)
+	}
+}
+
+func TestMemoryStoreUpcomingDepartures(t *testing.T) {
+	store := newTestMemoryStore(t)
+
+	tests := []struct {
+		at       time.Time
+		window   time.Duration
+		expected []int
+	}{
+		// Saturday morning
+		{time.Date(2018, 10, 27, 9, 0, 0, 0, warsaw), 2 * time.Hour, []int{32}},
+		// Thursday night, trip after midnight runs on Friday
+		{time.Date(2018, 10, 25, 23, 0, 0, 0, warsaw), 3 * time.Hour, []int{61}},
+		{time.Date(2018, 10, 25, 23, 0, 0, 0, warsaw), 30 * time.Minute, nil},
+		// Friday 1:10, still within Thursday's service
+		{time.Date(2018, 10, 26, 1, 10, 30, 0, warsaw), 0, []int{61, 94}},
+	}
+
+	for _, test := range tests {
+		data, err := store.GetUpcomingDepartures([]string{"Plac Grunwaldzki"}, DepartureQuery{At: test.at, Window: test.window})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var tripIDs []int
+		for _, stop := range data {
+			for _, departure := range stop.Departures {
+				tripIDs = append(tripIDs, departure.TripID)
+			}
+		}
+		if !reflect.DeepEqual(tripIDs, test.expected) {
+			t.Errorf(`Wrong result for "%s". Got "%v", expected: "%v"`, test.at, tripIDs, test.expected)
+		}
+	}
+}
+
+func TestMemoryStoreFilteredDepartures(t *testing.T) {
+	store := newTestMemoryStore(t)
+	at := time.Date(2018, 10, 26, 0, 0, 0, 0, warsaw)
+
+	tests := []struct {
+		query    DepartureQuery
+		expected []int
+	}{
+		{DepartureQuery{At: at}, []int{61, 94}},
+		{DepartureQuery{At: at, Limit: 1}, []int{61}},
+		{DepartureQuery{At: at, RouteIDs: []string{"33"}, Headsigns: []string{"pilczyce"}}, []int{61, 94}},
+		{DepartureQuery{At: at, RouteIDs: []string{"D"}}, nil},
+		{DepartureQuery{At: at, Headsigns: []string{"PLAC GRUNWALDZKI"}}, nil},
+		{DepartureQuery{At: at, Vehicle: Tram}, []int{61, 94}},
+		{DepartureQuery{At: at, Vehicle: Bus}, nil},
+	}
+
+	for _, test := range tests {
+		data, err := store.GetUpcomingDepartures([]string{"Plac Grunwaldzki"}, test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var tripIDs []int
+		for _, stop := range data {
+			for _, departure := range stop.Departures {
+				tripIDs = append(tripIDs, departure.TripID)
+			}
+		}
+		if !reflect.DeepEqual(tripIDs, test.expected) {
+			t.Errorf(`Wrong result for %+v. Got "%v", expected: "%v"`, test.query, tripIDs, test.expected)
+		}
+	}
+}
+
+func TestMemoryStoreDepartureBoard(t *testing.T) {
+	store := newTestMemoryStore(t)
+	query := DepartureQuery{At: time.Date(2018, 10, 25, 23, 0, 0, 0, warsaw), Window: 3 * time.Hour}
+
+	board, err := store.GetDepartureBoard([]string{"Pilczyce", "Plac Grunwaldzki", "Pilczyce"}, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type row struct {
+		TripID int
+		StopID int
+		Time   string
+	}
+	var result []row
+	for _, departure := range board.Departures {
+		result = append(result, row{departure.TripID, departure.StopID, departure.DepartureTime.Display()})
+	}
+	expected := []row{{61, 1, "01:10"}, {61, 2, "01:21"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, expected)
+	}
+}
+
+func TestMemoryStoreStopLookup(t *testing.T) {
+	store := newTestMemoryStore(t)
+
+	stop, err := store.GetStopByCode(20003)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Stop{"Plac Grunwaldzki", 3, 51.12, 17.06, 20003}
+	if stop != expected {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, stop, expected)
+	}
+
+	if _, err := store.GetStopByID(42); err != ErrStopNotFound {
+		t.Errorf("Wrong error for unknown stop: %v", err)
+	}
+
+	variants, _ := store.GetRouteVariantsByStopID(3)
+	if len(variants) != 1 || !reflect.DeepEqual(variants[0].TripIDs, []int{43}) {
+		t.Errorf("Wrong variants: %v", variants)
+	}
+
+	tt, err := store.GetTimetableAtStop("33", 3, "Plac Grunwaldzki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tt.Sundays) != 1 || tt.Sundays[0].TripID != 43 || tt.StopName != "Plac Grunwaldzki" {
+		t.Errorf("Wrong timetable: %v", tt)
+	}
+
+	tt, _ = store.GetTimetableAtStop("33", 1, "Plac Grunwaldzki")
+	if len(tt.Sundays) != 0 {
+		t.Errorf("Wrong timetable: %v", tt)
+	}
+}
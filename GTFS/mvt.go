@@ -0,0 +1,177 @@
+package GTFS
+
+import (
+	"math"
+	"sort"
+)
+
+// Encoder of Mapbox Vector Tiles (version 2.1 of the specification).
+
+const (
+	tileExtent = 4096
+	// features are kept this far outside of the tile, so that lines and symbols aren't cut at tile edges
+	tileBuffer = 64
+
+	mvtPoint      = 1
+	mvtLineString = 2
+
+	mvtMoveTo = 1
+	mvtLineTo = 2
+)
+
+func zigzag(value int) uint32 {
+	v := int32(value)
+	return uint32((v << 1) ^ (v >> 31))
+}
+
+// tilePosition is a position within tile, in units of its extent
+type tilePosition struct {
+	X int
+	Y int
+}
+
+// tileCoordinates converts latitude and longitude to Web Mercator position within tile z/x/y.
+func tileCoordinates(latitude, longitude float64, z, x, y int) tilePosition {
+	scale := math.Pow(2, float64(z))
+	lat := latitude * math.Pi / 180
+	worldX := (longitude + 180) / 360 * scale
+	worldY := (1 - math.Log(math.Tan(lat)+1/math.Cos(lat))/math.Pi) / 2 * scale
+	return tilePosition{
+		int(math.Round((worldX - float64(x)) * tileExtent)),
+		int(math.Round((worldY - float64(y)) * tileExtent)),
+	}
+}
+
+// inTile tells if position lies within tile or its buffer
+func inTile(position tilePosition) bool {
+	return position.X >= -tileBuffer && position.X <= tileExtent+tileBuffer &&
+		position.Y >= -tileBuffer && position.Y <= tileExtent+tileBuffer
+}
+
+// clipSegment cuts segment to the tile with its buffer (Liang-Barsky), ok is false if it lies outside.
+func clipSegment(a, b tilePosition) (tilePosition, tilePosition, bool) {
+	const low, high = -tileBuffer, tileExtent + tileBuffer
+	dx, dy := float64(b.X-a.X), float64(b.Y-a.Y)
+	t0, t1 := 0.0, 1.0
+	for _, edge := range [4][2]float64{
+		{-dx, float64(a.X - low)},
+		{dx, float64(high - a.X)},
+		{-dy, float64(a.Y - low)},
+		{dy, float64(high - a.Y)},
+	} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return a, b, false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 && t > t0 {
+			t0 = t
+		} else if p > 0 && t < t1 {
+			t1 = t
+		}
+		if t0 > t1 {
+			return a, b, false
+		}
+	}
+
+	at := func(t float64) tilePosition {
+		return tilePosition{a.X + int(math.Round(t*dx)), a.Y + int(math.Round(t*dy))}
+	}
+	return at(t0), at(t1), true
+}
+
+type mvtFeature struct {
+	geometryType int
+	// commands and parameters
+	geometry []uint32
+	tags     map[string]string
+}
+
+// mvtLayer collects features and deduplicates their keys and values
+type mvtLayer struct {
+	name     string
+	features []mvtFeature
+}
+
+func (layer *mvtLayer) addPoint(position tilePosition, tags map[string]string) {
+	geometry := []uint32{mvtMoveTo | 1<<3, zigzag(position.X), zigzag(position.Y)}
+	layer.features = append(layer.features, mvtFeature{mvtPoint, geometry, tags})
+}
+
+// addLines adds parts of given lines, each with at least two distinct positions, as one (multi)linestring.
+func (layer *mvtLayer) addLines(lines [][]tilePosition, tags map[string]string) {
+	var geometry []uint32
+	var cursor tilePosition
+	for _, line := range lines {
+		geometry = append(geometry, mvtMoveTo|1<<3, zigzag(line[0].X-cursor.X), zigzag(line[0].Y-cursor.Y))
+		geometry = append(geometry, uint32(mvtLineTo|(len(line)-1)<<3))
+		for i := 1; i < len(line); i++ {
+			geometry = append(geometry, zigzag(line[i].X-line[i-1].X), zigzag(line[i].Y-line[i-1].Y))
+		}
+		cursor = line[len(line)-1]
+	}
+	if geometry != nil {
+		layer.features = append(layer.features, mvtFeature{mvtLineString, geometry, tags})
+	}
+}
+
+func (layer *mvtLayer) encode() []byte {
+	var keys, values []string
+	keyIndexes, valueIndexes := map[string]int{}, map[string]int{}
+	index := func(value string, indexes map[string]int, list *[]string) uint32 {
+		if idx, ok := indexes[value]; ok {
+			return uint32(idx)
+		}
+		indexes[value] = len(*list)
+		*list = append(*list, value)
+		return uint32(len(*list) - 1)
+	}
+
+	var buffer protoBuffer
+	buffer.uintField(15, 2)
+	buffer.bytesField(1, []byte(layer.name))
+	for id, feature := range layer.features {
+		// sorted for reproducible tiles
+		var tagKeys []string
+		for key := range feature.tags {
+			tagKeys = append(tagKeys, key)
+		}
+		sort.Strings(tagKeys)
+
+		var tags []uint32
+		for _, key := range tagKeys {
+			tags = append(tags, index(key, keyIndexes, &keys), index(feature.tags[key], valueIndexes, &values))
+		}
+
+		var encoded protoBuffer
+		encoded.uintField(1, uint64(id+1))
+		encoded.packedField(2, tags)
+		encoded.uintField(3, uint64(feature.geometryType))
+		encoded.packedField(4, feature.geometry)
+		buffer.bytesField(2, encoded)
+	}
+	for _, key := range keys {
+		buffer.bytesField(3, []byte(key))
+	}
+	for _, value := range values {
+		var encoded protoBuffer
+		encoded.bytesField(1, []byte(value))
+		buffer.bytesField(4, encoded)
+	}
+	buffer.uintField(5, tileExtent)
+	return buffer
+}
+
+// encodeTile returns tile with given layers, skipping empty ones
+func encodeTile(layers ...*mvtLayer) []byte {
+	var buffer protoBuffer
+	for _, layer := range layers {
+		if len(layer.features) > 0 {
+			buffer.bytesField(3, layer.encode())
+		}
+	}
+	return buffer
+}
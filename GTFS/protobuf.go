@@ -0,0 +1,146 @@
+package GTFS
+
+import (
+	"errors"
+	"math"
+)
+
+// Just enough of protocol buffers wire format to write vector tiles and read and write GTFS-Realtime feeds
+// without generated code.
+
+var errInvalidProto = errors.New("invalid protocol buffers message")
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+type protoBuffer []byte
+
+func (buffer *protoBuffer) varint(value uint64) {
+	for value >= 0x80 {
+		*buffer = append(*buffer, byte(value)|0x80)
+		value >>= 7
+	}
+	*buffer = append(*buffer, byte(value))
+}
+
+func (buffer *protoBuffer) key(field, wireType int) {
+	buffer.varint(uint64(field<<3 | wireType))
+}
+
+func (buffer *protoBuffer) uintField(field int, value uint64) {
+	buffer.key(field, 0)
+	buffer.varint(value)
+}
+
+func (buffer *protoBuffer) bytesField(field int, value []byte) {
+	buffer.key(field, 2)
+	buffer.varint(uint64(len(value)))
+	*buffer = append(*buffer, value...)
+}
+
+func (buffer *protoBuffer) packedField(field int, values []uint32) {
+	var packed protoBuffer
+	for _, value := range values {
+		packed.varint(uint64(value))
+	}
+	buffer.bytesField(field, packed)
+}
+
+func (buffer *protoBuffer) stringField(field int, value string) {
+	buffer.bytesField(field, []byte(value))
+}
+
+// protoField is a field read from a message
+type protoField struct {
+	number   int
+	wireType int
+	// varint and fixed size values
+	value uint64
+	// length-delimited values: strings, bytes, embedded messages and packed fields
+	bytes []byte
+}
+
+func readVarint(data []byte, pos int) (uint64, int, error) {
+	var value uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if pos >= len(data) {
+			return 0, pos, errInvalidProto
+		}
+		b := data[pos]
+		pos++
+		value |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return value, pos, nil
+		}
+	}
+	return 0, pos, errInvalidProto
+}
+
+// parseProto splits message into its fields, in order they appear.
+func parseProto(data []byte) ([]protoField, error) {
+	var fields []protoField
+	for pos := 0; pos < len(data); {
+		key, next, err := readVarint(data, pos)
+		if err != nil {
+			return nil, err
+		}
+		pos = next
+
+		field := protoField{number: int(key >> 3), wireType: int(key & 7)}
+		switch field.wireType {
+		case wireVarint:
+			field.value, pos, err = readVarint(data, pos)
+			if err != nil {
+				return nil, err
+			}
+		case wireFixed64, wireFixed32:
+			size := 8
+			if field.wireType == wireFixed32 {
+				size = 4
+			}
+			if pos+size > len(data) {
+				return nil, errInvalidProto
+			}
+			for i := size - 1; i >= 0; i-- {
+				field.value = field.value<<8 | uint64(data[pos+i])
+			}
+			pos += size
+		case wireBytes:
+			length, next, err := readVarint(data, pos)
+			if err != nil || length > uint64(len(data)-next) {
+				return nil, errInvalidProto
+			}
+			field.bytes = data[next : next+int(length)]
+			pos = next + int(length)
+		default:
+			return nil, errInvalidProto
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+func (field protoField) int64() int64 {
+	return int64(field.value)
+}
+
+// int32 reads int32 and enum values, negative ones are sign-extended to 64 bits
+func (field protoField) int32() int32 {
+	return int32(field.value)
+}
+
+func (field protoField) float32() float32 {
+	return math.Float32frombits(uint32(field.value))
+}
+
+func (field protoField) float64() float64 {
+	return math.Float64frombits(field.value)
+}
+
+func (field protoField) string() string {
+	return string(field.bytes)
+}
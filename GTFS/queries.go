@@ -2,10 +2,39 @@ package GTFS
 
 const getAllStopNamesQuery = `
 	MATCH (s:Stop)
-    RETURN DISTINCT s.name, s.stopID, s.latitude, s.longitude
+    RETURN DISTINCT s.name, s.stopID, s.latitude, s.longitude, s.code
 	ORDER BY s.name;
 `
 
+const getStopByIDQuery = `
+	MATCH (s:Stop {stopID: {stopID}})
+	RETURN s.name, s.stopID, s.latitude, s.longitude, s.code
+`
+
+const getStopByCodeQuery = `
+	MATCH (s:Stop {code: {code}})
+	RETURN s.name, s.stopID, s.latitude, s.longitude, s.code
+	ORDER BY s.stopID
+	LIMIT 1
+`
+
+const getRoutesByStopQuery = `
+	MATCH (s:Stop)<-[:happens_at]-(st:StopTime)
+	MATCH (t:Trip {tripID: st.tripID})
+	RETURN s.stopID, collect(DISTINCT t.routeID)
+`
+
+const getTransfersQuery = `
+	MATCH (:Stop {stopID: {stopID}})-[w:walk]->(s:Stop)
+	RETURN s.name, s.stopID, s.latitude, s.longitude, s.code, w.distance, w.duration
+	ORDER BY w.distance
+`
+
+const getFootpathsQuery = `
+	MATCH (from:Stop)-[w:walk]->(to:Stop)
+	RETURN from.stopID, to.name, to.stopID, to.latitude, to.longitude, to.code, w.distance, w.duration
+`
+
 const getAllRouteIDsQuery = `
 	MATCH (t:Trip)
 	WITH t
@@ -48,6 +77,23 @@ const getRouteVariantsByStopNameQuery = `
 	ORDER BY routeID;
 `
 
+const getRouteVariantsByStopIDQuery = `
+	MATCH (st:StopTime)-[:happens_at]->(stop:Stop{stopID: {stopID}})
+	WITH st.tripID as tripID
+	MATCH (trip:Trip{tripID: tripID})-[:starts_at]-(st:StopTime)-[:happens_at]->(stop:Stop)
+	WITH trip.tripID as tripID, stop.name as firstStopName
+	MATCH (trip:Trip{tripID: tripID})-[:ends_at]-(st:StopTime)-[:happens_at]->(stop:Stop)
+	WITH trip, firstStopName, stop
+	MATCH (:Route{routeID: trip.routeID})-[:is_type]-(routeType:RouteType)
+	RETURN
+		trip.routeID as routeID,
+		routeType.name as routeType,
+		firstStopName,
+		stop.name as lastStopName,
+		collect(trip.tripID) as tripIDs
+	ORDER BY routeID;
+`
+
 const getRouteDirectionsQuery = `
 	MATCH (t:Trip {routeID: {routeID}})
 	WITH t.headsign as headsign, count(t.tripID) as cnt
@@ -74,10 +120,13 @@ const getTimetableQuery = `
 	WITH collect(s.stopID) as stopIDs, tripIDs
 	MATCH (st:StopTime)
 	WHERE st.tripID IN tripIDs AND st.stopID in stopIDs
+	MATCH (t:Trip {tripID: st.tripID})
 	RETURN
 		st.tripID as tripID,
 		st.arrivalTime as arrivalTime,
-		st.departureTime as departureTime;
+		st.departureTime as departureTime,
+		t.serviceID as serviceID,
+		st.stopID as stopID;
 `
 
 const getRouteInfoQuery = `
@@ -91,18 +140,19 @@ const getRouteInfoQuery = `
         route.validUntil as validUntil,
         agency.name as agencyName,
         agency.url as agencyUrl,
-        agency.phone as agencyPhone;
+        agency.phone as agencyPhone,
+        coalesce(route.color, '') as color;
 `
 
 const getTripTimelineQuery = `
 	MATCH p=(t:Trip {tripID: {tripID}})-[:starts_at]-(:StopTime)-[:next*]-(:StopTime)-[:ends_at]-(t)
 	WITH filter(n in nodes(p) WHERE EXISTS(n.stopID)) as nodes
-	WITH extract(n in nodes | [n.stopID, n.departureTime, n.onDemand]) AS tuple
+	WITH extract(n in nodes | [n.stopID, n.departureTime, n.onDemand, n.stopSequence]) AS tuple
 	UNWIND tuple as tuples
-	WITH tuples[0] as stopID, tuples[1] as departureTime, tuples[2] as onDemand
+	WITH tuples[0] as stopID, tuples[1] as departureTime, tuples[2] as onDemand, tuples[3] as stopSequence
 
 	MATCH (s:Stop {stopID: stopID})
-	RETURN s.name as stopName, departureTime, onDemand
+	RETURN s.name as stopName, departureTime, onDemand, stopID, stopSequence
 `
 
 const getStopsForRouteIDQuery = `
@@ -136,6 +186,42 @@ const getShapeQuery = `
 	ORDER BY s.shapeSequence
 `
 
+const getFeedInfoQuery = `
+	MATCH (info:FeedInfo)
+	RETURN coalesce(info.version, ''), info.startDate, info.endDate
+`
+
+const getAgencyIDsQuery = `
+	MATCH (agency:Agency)
+	RETURN agency.agencyID
+	ORDER BY agency.agencyID
+`
+
+const getNetworkShapesQuery = `
+	MATCH (t:Trip)
+	WITH DISTINCT t.routeID as routeID, t.shapeID as shapeID
+	MATCH (route:Route {routeID: routeID})-[:is_type]->(routeType:RouteType)
+	RETURN routeID, shapeID, routeType.name, coalesce(route.color, '')
+	ORDER BY routeID, shapeID
+`
+
+const getAllShapePointsQuery = `
+	MATCH (s: ShapePoint)
+	RETURN s.shapeID, s.shapeSequence, s.latitude, s.longitude
+	ORDER BY s.shapeID, s.shapeSequence
+`
+
+const getTripRouteIDQuery = `
+	MATCH (t:Trip {tripID: {tripID}})
+	RETURN t.routeID as routeID
+`
+
+const getTripQuery = `
+	MATCH (t:Trip {tripID: {tripID}})
+	MATCH (:Route {routeID: t.routeID})-[:is_type]->(routeType:RouteType)
+	RETURN t.routeID as routeID, t.headsign as headsign, routeType.name as routeType
+`
+
 const getTripStopsQuery = `
 	MATCH p=(t:Trip {tripID: {tripID}})-[:starts_at]-(:StopTime)-[:next*]-(:StopTime)-[:ends_at]-(t)
     WITH filter(n in nodes(p) WHERE EXISTS(n.stopID)) as nodes
@@ -144,7 +230,7 @@ const getTripStopsQuery = `
     WITH tuples[0] as stopID, tuples[1] as stopSequence, tuples[2] as onDemand
 
     MATCH (s:Stop {stopID: stopID})
-    RETURN s.name, s.stopID, s.latitude, s.longitude, onDemand
+    RETURN s.name, s.stopID, s.latitude, s.longitude, onDemand, s.code
     ORDER BY stopSequence
 `
 
@@ -160,6 +246,246 @@ const getUpcomingDeparturesQuery = `
 	MATCH (stop:Stop {name: {stopName}})<-[:happens_at]-(st: StopTime)
 	WITH stop, st
 	MATCH (t:Trip {tripID: st.tripID})
-	RETURN stop.stopID, stop.name, stop.latitude, stop.longitude, st.tripID, st.departureTime, st.onDemand, t.routeID, t.headsign
+	MATCH (:Route {routeID: t.routeID})-[:is_type]->(routeType:RouteType)
+	RETURN stop.stopID, stop.name, stop.latitude, stop.longitude, st.tripID, st.departureTime, st.onDemand, t.routeID, t.headsign, t.serviceID, routeType.name, stop.code, st.stopSequence
 	ORDER BY st.departureTime
 `
+
+const getPlannerTripsQuery = `
+	MATCH (t:Trip)
+	MATCH (:Route {routeID: t.routeID})-[:is_type]->(routeType:RouteType)
+	RETURN t.tripID, t.routeID, t.headsign, t.serviceID, routeType.name, t.shapeID
+	ORDER BY t.tripID
+`
+
+const getPlannerStopTimesQuery = `
+	MATCH (st:StopTime)
+	RETURN st.tripID, st.stopID, st.stopSequence, st.arrivalTime, st.departureTime, st.onDemand
+	ORDER BY st.tripID, st.stopSequence
+`
+
+const getCalendarsQuery = `
+	MATCH (c:Calendar)
+	RETURN c.serviceID, [c.sunday, c.monday, c.tuesday, c.wednesday, c.thursday, c.friday, c.saturday], c.startDate, c.endDate
+`
+
+const getCalendarDatesQuery = `
+	MATCH (d:CalendarDate)
+	RETURN d.serviceID, d.date, d.exceptionType
+`
+
+// Import node marks a finished import, so that servers notice the graph was replaced
+const setImportedAtQuery = `
+	MERGE (i:Import)
+	SET i.finishedAt = {finishedAt}
+`
+
+const getImportedAtQuery = `
+	MATCH (i:Import)
+	RETURN i.finishedAt
+`
+
+const clearImportedAtQuery = `
+	MATCH (i:Import)
+	DELETE i
+`
+
+// clearGraphQuery removes a batch of nodes of the served feed, leaving the staged one
+const clearGraphQuery = `
+	MATCH (n)
+	WHERE none(label IN labels(n) WHERE label STARTS WITH 'Staged')
+	WITH n LIMIT 10000
+	DETACH DELETE n
+	RETURN count(*)
+`
+
+// clearStagedQuery removes a batch of nodes left over by a failed import
+const clearStagedQuery = `
+	MATCH (n)
+	WHERE any(label IN labels(n) WHERE label STARTS WITH 'Staged')
+	WITH n LIMIT 10000
+	DETACH DELETE n
+	RETURN count(*)
+`
+
+// unstageQuery moves a batch of staged nodes to the label given in place of %[1]s
+const unstageQuery = `
+	MATCH (n:Staged%[1]s)
+	WITH n LIMIT 10000
+	REMOVE n:Staged%[1]s
+	SET n:%[1]s
+	RETURN count(*)
+`
+
+const createTripsQuery = `
+	UNWIND {rows} AS row
+	CREATE (:Trip {
+		tripID:    row.tripID,
+		routeID:   row.routeID,
+		serviceID: row.serviceID,
+		vehicleID: row.vehicleID,
+		variantID: row.variantID,
+		headsign:  row.headsign,
+		shapeID:   row.shapeID
+	})
+`
+
+const createStopsQuery = `
+	UNWIND {rows} AS row
+	CREATE (:Stop {
+		stopID:    row.stopID,
+		code:      row.code,
+		name:      row.name,
+		latitude:  row.latitude,
+		longitude: row.longitude
+	})
+`
+
+const createStopTimesQuery = `
+	UNWIND {rows} AS row
+	CREATE (:StopTime {
+		tripID:        row.tripID,
+		arrivalTime:   row.arrivalTime,
+		departureTime: row.departureTime,
+		stopID:        row.stopID,
+		stopSequence:  row.stopSequence,
+		onDemand:      row.onDemand
+	})
+`
+
+const createVehicleTypesQuery = `
+	UNWIND {rows} AS row
+	CREATE (:VehicleType {
+		vehicleID:   row.vehicleID,
+		name:        row.name,
+		description: row.description,
+		symbol:      row.symbol
+	})
+`
+
+const createRoutesQuery = `
+	UNWIND {rows} AS row
+	CREATE (:Route {
+		routeID:    row.routeID,
+		agencyID:   row.agencyID,
+		typeID:     row.typeID,
+		validFrom:  row.validFrom,
+		validUntil: row.validUntil,
+		color:      row.color
+	})
+`
+
+const createRouteTypesQuery = `
+	UNWIND {rows} AS row
+	CREATE (:RouteType {
+		typeID: row.typeID,
+		name:   row.name
+	})
+`
+
+const createAgenciesQuery = `
+	UNWIND {rows} AS row
+	CREATE (:Agency {
+		agencyID: row.agencyID,
+		name:     row.name,
+		url:      row.url,
+		phone:    row.phone
+	})
+`
+
+const createFeedInfoQuery = `
+	UNWIND {rows} AS row
+	CREATE (:FeedInfo {
+		publisherName: row.publisherName,
+		publisherURL:  row.publisherURL,
+		lang:          row.lang,
+		startDate:     row.startDate,
+		endDate:       row.endDate,
+		version:       row.version
+	})
+`
+
+const createCalendarsQuery = `
+	UNWIND {rows} AS row
+	CREATE (:Calendar {
+		serviceID: row.serviceID,
+		monday:    row.monday,
+		tuesday:   row.tuesday,
+		wednesday: row.wednesday,
+		thursday:  row.thursday,
+		friday:    row.friday,
+		saturday:  row.saturday,
+		sunday:    row.sunday,
+		startDate: row.startDate,
+		endDate:   row.endDate
+	})
+`
+
+const createCalendarDatesQuery = `
+	UNWIND {rows} AS row
+	CREATE (:CalendarDate {
+		serviceID:     row.serviceID,
+		date:          row.date,
+		exceptionType: row.exceptionType
+	})
+`
+
+const createShapePointsQuery = `
+	UNWIND {rows} AS row
+	CREATE (:ShapePoint {
+		shapeID:       row.shapeID,
+		latitude:      row.latitude,
+		longitude:     row.longitude,
+		shapeSequence: row.shapeSequence
+	})
+`
+
+var createIndexesQueries = []string{
+	`CREATE INDEX ON :Trip(tripID)`,
+	`CREATE INDEX ON :Stop(stopID)`,
+	`CREATE INDEX ON :Stop(name)`,
+	`CREATE INDEX ON :Stop(code)`,
+	`CREATE INDEX ON :StopTime(tripID)`,
+	`CREATE INDEX ON :VehicleType(vehicleID)`,
+	`CREATE INDEX ON :Route(routeID)`,
+	`CREATE INDEX ON :RouteType(typeID)`,
+	`CREATE INDEX ON :Agency(agencyID)`,
+	`CREATE INDEX ON :ShapePoint(shapeID)`,
+	`CREATE INDEX ON :Calendar(serviceID)`,
+	`CALL db.awaitIndexes()`,
+}
+
+const createStartsEndsAtQuery = `
+	UNWIND {rows} AS row
+	MATCH (trip:Trip {tripID: row.tripID})
+	MATCH (first:StopTime {tripID: row.tripID, stopSequence: row.first})
+	MATCH (last:StopTime {tripID: row.tripID, stopSequence: row.last})
+	CREATE (trip)-[:starts_at]->(first)
+	CREATE (trip)-[:ends_at]->(last)
+`
+
+const createNextQuery = `
+	UNWIND {rows} AS row
+	MATCH (st1:StopTime {tripID: row.tripID, stopSequence: row.from})
+	MATCH (st2:StopTime {tripID: row.tripID, stopSequence: row.to})
+	CREATE (st1)-[:next]->(st2)
+`
+
+const createWalkQuery = `
+	UNWIND {rows} AS row
+	MATCH (from:Stop {stopID: row.from})
+	MATCH (to:Stop {stopID: row.to})
+	CREATE (from)-[:walk {distance: row.distance, duration: row.duration}]->(to)
+`
+
+const createHappensAtQuery = `
+	UNWIND {rows} AS tripID
+	MATCH (st:StopTime {tripID: tripID})
+	MATCH (stop:Stop {stopID: st.stopID})
+	CREATE (st)-[:happens_at]->(stop)
+`
+
+const createIsTypeQuery = `
+	MATCH (route:Route), (routeType:RouteType {typeID: route.typeID})
+	CREATE (route)-[:is_type]->(routeType)
+`
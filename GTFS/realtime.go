@@ -0,0 +1,629 @@
+package GTFS
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoRealtime is returned for realtime data when no feed is configured.
+var ErrNoRealtime = errors.New("realtime data isn't available")
+
+// ErrVehicleNotFound is returned when no vehicle reports running given trip.
+var ErrVehicleNotFound = errors.New("vehicle not found")
+
+// VehicleSource gives realtime positions of vehicles. Stores wrapped WithRealtime implement it.
+type VehicleSource interface {
+	GetVehicles(query VehicleQuery) ([]LiveVehicle, error)
+	GetTripVehicle(tripID int) (LiveVehicle, error)
+}
+
+// DefaultRealtimeStaleAfter is how old realtime data can get before departures fall back to the timetable.
+const DefaultRealtimeStaleAfter = 3 * time.Minute
+
+// schedule relationships of GTFS-Realtime trips and stop times
+const (
+	rtTripCanceled = 3
+	rtStopSkipped  = 1
+)
+
+// Prediction is realtime estimate of a departure.
+type Prediction struct {
+	Time ServiceTime
+	// seconds, negative when early
+	Delay    int
+	Canceled bool
+}
+
+type stopTimeEvent struct {
+	hasDelay bool
+	delay    int
+	// unix time, zero if not given
+	time int64
+}
+
+func (event stopTimeEvent) known() bool {
+	return event.hasDelay || event.time != 0
+}
+
+type stopTimeUpdate struct {
+	// -1 if not given
+	stopSequence int
+	// zero if not given
+	stopID    int
+	arrival   stopTimeEvent
+	departure stopTimeEvent
+	skipped   bool
+}
+
+type tripUpdate struct {
+	tripID int
+	// service date of the run, zero if not given
+	startDate time.Time
+	canceled  bool
+	// trip-wide delay, used for stops without updates
+	delay stopTimeEvent
+	stops []stopTimeUpdate
+}
+
+var vehicleStatuses = []string{"INCOMING_AT", "STOPPED_AT", "IN_TRANSIT_TO"}
+
+var occupancyStatuses = []string{
+	"EMPTY",
+	"MANY_SEATS_AVAILABLE",
+	"FEW_SEATS_AVAILABLE",
+	"STANDING_ROOM_ONLY",
+	"CRUSHED_STANDING_ROOM_ONLY",
+	"FULL",
+	"NOT_ACCEPTING_PASSENGERS",
+	"NO_DATA_AVAILABLE",
+	"NOT_BOARDABLE",
+}
+
+// enumName returns name of enum value, or its number if it's unknown
+func enumName(names []string, value int32) string {
+	if value >= 0 && int(value) < len(names) {
+		return names[value]
+	}
+	return strconv.Itoa(int(value))
+}
+
+// LiveVehicle is a vehicle position reported by GTFS-Realtime feed, joined with its trip.
+type LiveVehicle struct {
+	VehicleID string
+	Label     string `json:",omitempty"`
+	// zero if trip isn't known
+	TripID    int
+	RouteID   string
+	Headsign  string `json:",omitempty"`
+	IsBus     bool
+	Latitude  float64
+	Longitude float64
+	// degrees clockwise from north, nil if not reported
+	Bearing *float64 `json:",omitempty"`
+	// metres per second
+	Speed *float64 `json:",omitempty"`
+	// "INCOMING_AT", "STOPPED_AT" or "IN_TRANSIT_TO" current or next stop
+	Status              string
+	StopID              int        `json:",omitempty"`
+	Occupancy           string     `json:",omitempty"`
+	OccupancyPercentage *int       `json:",omitempty"`
+	Timestamp           *time.Time `json:",omitempty"`
+}
+
+// tripRun identifies a run of a trip by trip ID and service date as "20060102"
+type tripRun struct {
+	tripID int
+	date   string
+}
+
+func runOf(tripID int, date time.Time) tripRun {
+	return tripRun{tripID, date.Format("20060102")}
+}
+
+// realtimeFeed is a parsed GTFS-Realtime FeedMessage
+type realtimeFeed struct {
+	timestamp time.Time
+	trips     map[tripRun]tripUpdate
+	vehicles  []LiveVehicle
+}
+
+// currentRun returns update of the trip's earliest run between the day before given time and the day after,
+// as a run from the previous service date may still be going after midnight.
+func (feed *realtimeFeed) currentRun(tripID int, now time.Time) (tripUpdate, bool) {
+	for days := -1; days <= 1; days++ {
+		if update, ok := feed.trips[runOf(tripID, serviceDate(now).AddDate(0, 0, days))]; ok {
+			return update, true
+		}
+	}
+	return tripUpdate{}, false
+}
+
+// parseRealtimeFeed reads FeedMessage. Entities with trip IDs not matching the static feed's format are skipped.
+// Trip updates without start date are taken to be for the current service date.
+func parseRealtimeFeed(data []byte) (*realtimeFeed, error) {
+	message, err := parseProto(data)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &realtimeFeed{trips: map[tripRun]tripUpdate{}}
+	var updates []tripUpdate
+	for _, field := range message {
+		switch field.number {
+		case 1:
+			header, err := parseProto(field.bytes)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range header {
+				if f.number == 3 && f.value > 0 {
+					feed.timestamp = time.Unix(f.int64(), 0)
+				}
+			}
+		case 2:
+			entity, err := parseProto(field.bytes)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range entity {
+				switch f.number {
+				case 3:
+					update, err := parseTripUpdate(f.bytes)
+					if err != nil {
+						return nil, err
+					}
+					if update.tripID != 0 {
+						updates = append(updates, update)
+					}
+				case 4:
+					vehicle, err := parseVehiclePosition(f.bytes)
+					if err != nil {
+						return nil, err
+					}
+					feed.vehicles = append(feed.vehicles, vehicle)
+				}
+			}
+		}
+	}
+
+	now := feed.timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	for _, update := range updates {
+		if update.startDate.IsZero() {
+			update.startDate = serviceDate(now)
+		}
+		feed.trips[runOf(update.tripID, update.startDate)] = update
+	}
+	return feed, nil
+}
+
+func parseTripUpdate(data []byte) (tripUpdate, error) {
+	var update tripUpdate
+	fields, err := parseProto(data)
+	if err != nil {
+		return update, err
+	}
+
+	for _, field := range fields {
+		switch field.number {
+		case 1:
+			trip, err := parseProto(field.bytes)
+			if err != nil {
+				return update, err
+			}
+			for _, f := range trip {
+				switch f.number {
+				case 1:
+					update.tripID, _ = parseTripID(f.string())
+				case 3:
+					update.startDate, _ = time.ParseInLocation("20060102", f.string(), warsaw)
+				case 4:
+					update.canceled = f.int32() == rtTripCanceled
+				}
+			}
+		case 2:
+			stop, err := parseStopTimeUpdate(field.bytes)
+			if err != nil {
+				return update, err
+			}
+			update.stops = append(update.stops, stop)
+		case 5:
+			update.delay = stopTimeEvent{hasDelay: true, delay: int(field.int32())}
+		}
+	}
+
+	sort.SliceStable(update.stops, func(i, j int) bool {
+		return update.stops[i].stopSequence < update.stops[j].stopSequence
+	})
+	return update, nil
+}
+
+func parseStopTimeUpdate(data []byte) (stopTimeUpdate, error) {
+	update := stopTimeUpdate{stopSequence: -1}
+	fields, err := parseProto(data)
+	if err != nil {
+		return update, err
+	}
+
+	for _, field := range fields {
+		switch field.number {
+		case 1:
+			update.stopSequence = int(field.value)
+		case 2, 3:
+			event, err := parseStopTimeEvent(field.bytes)
+			if err != nil {
+				return update, err
+			}
+			if field.number == 2 {
+				update.arrival = event
+			} else {
+				update.departure = event
+			}
+		case 4:
+			update.stopID, _ = strconv.Atoi(field.string())
+		case 5:
+			update.skipped = field.int32() == rtStopSkipped
+		}
+	}
+	return update, nil
+}
+
+func parseStopTimeEvent(data []byte) (stopTimeEvent, error) {
+	var event stopTimeEvent
+	fields, err := parseProto(data)
+	if err != nil {
+		return event, err
+	}
+
+	for _, field := range fields {
+		switch field.number {
+		case 1:
+			event.hasDelay, event.delay = true, int(field.int32())
+		case 2:
+			event.time = field.int64()
+		}
+	}
+	return event, nil
+}
+
+func parseVehiclePosition(data []byte) (LiveVehicle, error) {
+	vehicle := LiveVehicle{Status: vehicleStatuses[2]}
+	fields, err := parseProto(data)
+	if err != nil {
+		return vehicle, err
+	}
+
+	for _, field := range fields {
+		switch field.number {
+		case 1:
+			trip, err := parseProto(field.bytes)
+			if err != nil {
+				return vehicle, err
+			}
+			for _, f := range trip {
+				switch f.number {
+				case 1:
+					vehicle.TripID, _ = parseTripID(f.string())
+				case 5:
+					vehicle.RouteID = f.string()
+				}
+			}
+		case 2:
+			position, err := parseProto(field.bytes)
+			if err != nil {
+				return vehicle, err
+			}
+			for _, f := range position {
+				value := float64(f.float32())
+				switch f.number {
+				case 1:
+					vehicle.Latitude = value
+				case 2:
+					vehicle.Longitude = value
+				case 3:
+					vehicle.Bearing = &value
+				case 5:
+					vehicle.Speed = &value
+				}
+			}
+		case 4:
+			vehicle.Status = enumName(vehicleStatuses, field.int32())
+		case 5:
+			timestamp := time.Unix(field.int64(), 0)
+			vehicle.Timestamp = &timestamp
+		case 7:
+			vehicle.StopID, _ = strconv.Atoi(field.string())
+		case 8:
+			descriptor, err := parseProto(field.bytes)
+			if err != nil {
+				return vehicle, err
+			}
+			for _, f := range descriptor {
+				switch f.number {
+				case 1:
+					vehicle.VehicleID = f.string()
+				case 2:
+					vehicle.Label = f.string()
+				}
+			}
+		case 9:
+			vehicle.Occupancy = enumName(occupancyStatuses, field.int32())
+		case 10:
+			percentage := int(field.int32())
+			vehicle.OccupancyPercentage = &percentage
+		}
+	}
+	return vehicle, nil
+}
+
+// delayAt returns delay of the event, comparing predicted time with scheduled one if delay isn't given
+func (event stopTimeEvent) delayAt(scheduled ServiceTime) int {
+	if event.hasDelay {
+		return event.delay
+	}
+	return int(event.time - scheduled.Time().Unix())
+}
+
+// predict returns prediction for trip's stop, or nil if the update says nothing about it. Delays propagate
+// from the last updated stop before, as GTFS-Realtime specifies. Scheduled time must be bound to a date.
+func (update tripUpdate) predict(stopSequence, stopID int, scheduled ServiceTime) *Prediction {
+	if update.canceled {
+		return &Prediction{scheduled, 0, true}
+	}
+
+	event := update.delay
+	for _, stop := range update.stops {
+		if stop.stopSequence == stopSequence || (stop.stopSequence < 0 && stop.stopID == stopID) {
+			if stop.skipped {
+				return &Prediction{scheduled, 0, true}
+			}
+			if stop.departure.known() {
+				event = stop.departure
+			} else if stop.arrival.known() {
+				event = stop.arrival
+			}
+			break
+		}
+		if stop.stopSequence < 0 || stop.stopSequence > stopSequence || stop.skipped {
+			continue
+		}
+		// departure delay of an earlier stop carries on
+		if stop.departure.known() {
+			event = stop.departure
+		} else if stop.arrival.known() {
+			event = stop.arrival
+		}
+	}
+
+	if !event.known() {
+		return nil
+	}
+	delay := event.delayAt(scheduled)
+	return &Prediction{ServiceTime{scheduled.Seconds + delay, scheduled.Date}, delay, false}
+}
+
+// Realtime keeps the latest GTFS-Realtime feed read from a URL or a local file.
+type Realtime struct {
+	source     string
+	staleAfter time.Duration
+	client     http.Client
+
+	mutex     sync.RWMutex
+	feed      *realtimeFeed
+	fetchedAt time.Time
+}
+
+func NewRealtime(source string, staleAfter time.Duration) *Realtime {
+	return &Realtime{source: source, staleAfter: staleAfter, client: http.Client{Timeout: 10 * time.Second}}
+}
+
+func (realtime *Realtime) fetch() ([]byte, error) {
+	if !strings.HasPrefix(realtime.source, "http://") && !strings.HasPrefix(realtime.source, "https://") {
+		return ioutil.ReadFile(realtime.source)
+	}
+
+	res, err := realtime.client.Get(realtime.source)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// Update reads the source again. The previous feed is kept if it fails.
+func (realtime *Realtime) Update() error {
+	data, err := realtime.fetch()
+	if err == nil {
+		var feed *realtimeFeed
+		if feed, err = parseRealtimeFeed(data); err == nil {
+			realtime.mutex.Lock()
+			realtime.feed, realtime.fetchedAt = feed, time.Now()
+			realtime.mutex.Unlock()
+			log.Printf(`Received %d trip updates and %d vehicle positions from %s`, len(feed.trips), len(feed.vehicles), realtime.source)
+			return nil
+		}
+	}
+
+	log.Printf(`Reading realtime feed %s failed: %s`, realtime.source, err)
+	return err
+}
+
+// current returns the feed if it's fresh enough at given time, nil otherwise
+func (realtime *Realtime) current(now time.Time) *realtimeFeed {
+	realtime.mutex.RLock()
+	defer realtime.mutex.RUnlock()
+
+	if realtime.feed == nil {
+		return nil
+	}
+	timestamp := realtime.feed.timestamp
+	if timestamp.IsZero() {
+		timestamp = realtime.fetchedAt
+	}
+	if now.Sub(timestamp) > realtime.staleAfter {
+		return nil
+	}
+	return realtime.feed
+}
+
+// realtimeStore adds realtime data to the underlying store.
+type realtimeStore struct {
+	Store
+	// either can be nil if it isn't configured
+	tripUpdates      *Realtime
+	vehiclePositions *Realtime
+}
+
+// WithRealtime returns store adding predictions from tripUpdates to departures and trip timelines, and serving
+// live vehicles from vehiclePositions. Either feed can be nil. When trip updates are missing or stale,
+// departures are flagged as such and keep scheduled times only.
+func WithRealtime(store Store, tripUpdates, vehiclePositions *Realtime) Store {
+	return &realtimeStore{store, tripUpdates, vehiclePositions}
+}
+
+func (store *realtimeStore) predict(departure *UpcomingDeparture, feed *realtimeFeed) {
+	if feed == nil {
+		departure.Stale = true
+		return
+	}
+	if update, ok := feed.trips[runOf(departure.TripID, departure.DepartureTime.Date)]; ok {
+		departure.Prediction = update.predict(departure.StopSequence, departure.Stop.ID, departure.DepartureTime)
+	}
+}
+
+func (store *realtimeStore) GetUpcomingDepartures(stopNames []string, query DepartureQuery) ([]UpcomingDepartures, error) {
+	data, err := store.Store.GetUpcomingDepartures(stopNames, query)
+	if err != nil || store.tripUpdates == nil {
+		return data, err
+	}
+
+	feed := store.tripUpdates.current(time.Now())
+	for _, stop := range data {
+		for i := range stop.Departures {
+			store.predict(&stop.Departures[i], feed)
+		}
+	}
+	return data, nil
+}
+
+func (store *realtimeStore) GetDepartureBoard(stopNames []string, query DepartureQuery) (DepartureBoard, error) {
+	board, err := store.Store.GetDepartureBoard(stopNames, query)
+	if err != nil || store.tripUpdates == nil {
+		return board, err
+	}
+
+	feed := store.tripUpdates.current(time.Now())
+	for i := range board.Departures {
+		store.predict(&board.Departures[i].UpcomingDeparture, feed)
+	}
+	return board, nil
+}
+
+// GetTripTimeline predicts times of trip's run on given service date. Without a date,
+// trip's current run is used, the earliest one in trip updates.
+func (store *realtimeStore) GetTripTimeline(tripID int, date time.Time) (TripTimeline, error) {
+	if store.tripUpdates == nil {
+		return store.Store.GetTripTimeline(tripID, date)
+	}
+
+	now := time.Now()
+	feed := store.tripUpdates.current(now)
+	update, ok := tripUpdate{}, false
+	if feed != nil && date.IsZero() {
+		update, ok = feed.currentRun(tripID, now)
+		if ok {
+			date = update.startDate
+		}
+	} else if feed != nil {
+		update, ok = feed.trips[runOf(tripID, serviceDate(date))]
+	}
+
+	timeline, err := store.Store.GetTripTimeline(tripID, date)
+	if err != nil {
+		return timeline, err
+	}
+	for i := range timeline.Timeline {
+		entry := &timeline.Timeline[i]
+		if feed == nil {
+			entry.Stale = true
+		} else if ok {
+			entry.Prediction = update.predict(entry.StopSequence, entry.StopID, entry.DepartureTime)
+		}
+	}
+	return timeline, nil
+}
+
+// liveVehicles returns vehicles from a fresh feed joined with their trips, nil if feed is stale.
+func (store *realtimeStore) liveVehicles() ([]LiveVehicle, error) {
+	if store.vehiclePositions == nil {
+		return nil, ErrNoRealtime
+	}
+	feed := store.vehiclePositions.current(time.Now())
+	if feed == nil {
+		return nil, nil
+	}
+
+	vehicles := make([]LiveVehicle, 0, len(feed.vehicles))
+	for _, vehicle := range feed.vehicles {
+		if vehicle.TripID != 0 {
+			trip, err := store.Store.GetTrip(vehicle.TripID)
+			if err == ErrTripNotFound {
+				vehicle.TripID = 0
+			} else if err != nil {
+				return nil, err
+			} else {
+				vehicle.RouteID, vehicle.Headsign, vehicle.IsBus = trip.RouteID, trip.Headsign, trip.IsBus
+			}
+		}
+		vehicles = append(vehicles, vehicle)
+	}
+	return vehicles, nil
+}
+
+func (store *realtimeStore) GetVehicles(query VehicleQuery) ([]LiveVehicle, error) {
+	vehicles, err := store.liveVehicles()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []LiveVehicle{}
+	for _, vehicle := range vehicles {
+		if query.matches(vehicle.RouteID, vehicle.Latitude, vehicle.Longitude) {
+			result = append(result, vehicle)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].RouteID != result[j].RouteID {
+			return result[i].RouteID < result[j].RouteID
+		}
+		return result[i].VehicleID < result[j].VehicleID
+	})
+	return result, nil
+}
+
+func (store *realtimeStore) GetTripVehicle(tripID int) (LiveVehicle, error) {
+	vehicles, err := store.liveVehicles()
+	if err != nil {
+		return LiveVehicle{}, err
+	}
+
+	for _, vehicle := range vehicles {
+		if vehicle.TripID == tripID {
+			return vehicle, nil
+		}
+	}
+	return LiveVehicle{}, ErrVehicleNotFound
+}
@@ -0,0 +1,272 @@
+package GTFS
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testTripUpdate encodes FeedEntity with a TripUpdate of given trip. stopDelays are stop sequence and delay pairs.
+func testTripUpdate(tripID string, canceled bool, stopDelays ...int) []byte {
+	return testDatedTripUpdate(tripID, "", canceled, stopDelays...)
+}
+
+// testDatedTripUpdate is testTripUpdate with start date as "20060102", none if empty
+func testDatedTripUpdate(tripID, startDate string, canceled bool, stopDelays ...int) []byte {
+	var trip protoBuffer
+	trip.stringField(1, tripID)
+	if startDate != "" {
+		trip.stringField(3, startDate)
+	}
+	if canceled {
+		trip.uintField(4, rtTripCanceled)
+	}
+
+	var update protoBuffer
+	update.bytesField(1, trip)
+	for i := 0; i < len(stopDelays); i += 2 {
+		var event, stop protoBuffer
+		event.uintField(1, uint64(int64(stopDelays[i+1])))
+		stop.uintField(1, uint64(stopDelays[i]))
+		stop.bytesField(3, event)
+		update.bytesField(2, stop)
+	}
+
+	var entity protoBuffer
+	entity.stringField(1, tripID)
+	entity.bytesField(3, update)
+	return entity
+}
+
+func testRealtimeFeed(timestamp time.Time, entities ...[]byte) []byte {
+	var header, message protoBuffer
+	header.stringField(1, "2.0")
+	header.uintField(3, uint64(timestamp.Unix()))
+	message.bytesField(1, header)
+	for _, entity := range entities {
+		message.bytesField(2, entity)
+	}
+	return message
+}
+
+func TestTripUpdatePredict(t *testing.T) {
+	feed, err := parseRealtimeFeed(testRealtimeFeed(time.Now(), testTripUpdate("3_2", false, 1, 120, 3, -60), testTripUpdate("4_3", true)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduled := ServiceTime{10 * 3600, time.Date(2018, 10, 27, 0, 0, 0, 0, warsaw)}
+
+	tests := []struct {
+		tripID       int
+		stopSequence int
+		expected     *Prediction
+	}{
+		// before the first update
+		{32, 0, nil},
+		{32, 1, &Prediction{ServiceTime{10*3600 + 120, scheduled.Date}, 120, false}},
+		// delay carries on
+		{32, 2, &Prediction{ServiceTime{10*3600 + 120, scheduled.Date}, 120, false}},
+		{32, 4, &Prediction{ServiceTime{10*3600 - 60, scheduled.Date}, -60, false}},
+		{43, 0, &Prediction{scheduled, 0, true}},
+	}
+
+	for _, test := range tests {
+		update, _ := feed.currentRun(test.tripID, time.Now())
+		result := update.predict(test.stopSequence, 0, scheduled)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, test.expected)
+		}
+	}
+
+	// predicted time instead of delay
+	update := tripUpdate{stops: []stopTimeUpdate{{stopSequence: -1, stopID: 7, departure: stopTimeEvent{time: scheduled.Time().Unix() + 90}}}}
+	if result := update.predict(5, 7, scheduled); result == nil || result.Delay != 90 {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, 90)
+	}
+}
+
+func TestRealtimeDepartures(t *testing.T) {
+	file, err := ioutil.TempFile("", "trip-updates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	realtime := NewRealtime(file.Name(), time.Minute)
+	store := WithRealtime(newTestMemoryStore(t), realtime, nil)
+	// Friday 1:10, trip 61 from Thursday's service and trip 94
+	query := DepartureQuery{At: time.Date(2018, 10, 26, 1, 10, 30, 0, warsaw)}
+
+	tests := []struct {
+		timestamp time.Time
+		// start dates of trips 61 and 94
+		dates    [2]string
+		expected []string
+	}{
+		{time.Now(), [2]string{"20181025", "20181026"}, []string{"61 01:12 delay 120", "94 12:00 canceled"}},
+		// updates for other runs of the same trips
+		{time.Now(), [2]string{"20181024", "20181102"}, []string{"61 none", "94 none"}},
+		// without start date updates are for today
+		{time.Now(), [2]string{"", ""}, []string{"61 none", "94 none"}},
+		{time.Now().Add(-time.Hour), [2]string{"20181025", "20181026"}, []string{"61 stale", "94 stale"}},
+	}
+
+	for _, test := range tests {
+		feed := testRealtimeFeed(test.timestamp,
+			testDatedTripUpdate("6_1", test.dates[0], false, 0, 120), testDatedTripUpdate("9_4", test.dates[1], true))
+		if err := ioutil.WriteFile(file.Name(), feed, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := realtime.Update(); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := store.GetUpcomingDepartures([]string{"Plac Grunwaldzki"}, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result []string
+		for _, departure := range data[0].Departures {
+			switch {
+			case departure.Stale:
+				result = append(result, fmt.Sprintf("%d stale", departure.TripID))
+			case departure.Prediction == nil:
+				result = append(result, fmt.Sprintf("%d none", departure.TripID))
+			case departure.Prediction.Canceled:
+				result = append(result, fmt.Sprintf("%d %s canceled", departure.TripID, departure.Prediction.Time.Display()))
+			default:
+				result = append(result, fmt.Sprintf("%d %s delay %d", departure.TripID, departure.Prediction.Time.Display(), departure.Prediction.Delay))
+			}
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, test.expected)
+		}
+	}
+}
+
+// testVehiclePosition encodes FeedEntity with a VehiclePosition
+func testVehiclePosition(vehicleID, tripID, routeID string, lat, lon, bearing float32, occupancy int) []byte {
+	float := func(buffer *protoBuffer, field int, value float32) {
+		buffer.key(field, wireFixed32)
+		*buffer = append(*buffer, make([]byte, 4)...)
+		binary.LittleEndian.PutUint32((*buffer)[len(*buffer)-4:], math.Float32bits(value))
+	}
+
+	var trip, position, descriptor, vehicle, entity protoBuffer
+	trip.stringField(1, tripID)
+	trip.stringField(5, routeID)
+	float(&position, 1, lat)
+	float(&position, 2, lon)
+	float(&position, 3, bearing)
+	descriptor.stringField(1, vehicleID)
+
+	vehicle.bytesField(1, trip)
+	vehicle.bytesField(2, position)
+	vehicle.uintField(4, 1)
+	vehicle.bytesField(8, descriptor)
+	vehicle.uintField(9, uint64(occupancy))
+
+	entity.stringField(1, vehicleID)
+	entity.bytesField(4, vehicle)
+	return entity
+}
+
+func TestRealtimeVehicles(t *testing.T) {
+	feed := testRealtimeFeed(time.Now(),
+		testVehiclePosition("2207", "99_9", "A", 51.2, 17.2, 0, 0),
+		testVehiclePosition("3301", "3_2", "", 51.12, 17.03, 90, 2))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(feed)
+	}))
+	defer server.Close()
+
+	w := httptest.NewRecorder()
+	VehiclesHandler(nil)(w, httptest.NewRequest("GET", "/vehicles", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf(`Wrong status code. Got %d, expected: %d`, w.Code, http.StatusServiceUnavailable)
+	}
+
+	realtime := NewRealtime(server.URL, time.Minute)
+	if err := realtime.Update(); err != nil {
+		t.Fatal(err)
+	}
+	store := WithRealtime(newTestMemoryStore(t), nil, realtime).(VehicleSource)
+
+	tests := []struct {
+		query    VehicleQuery
+		expected []string
+	}{
+		{VehicleQuery{}, []string{"3301 32 33 PILCZYCE 51.12,17.03 90 STOPPED_AT FEW_SEATS_AVAILABLE", "2207 0 A  51.20,17.20 0 STOPPED_AT EMPTY"}},
+		{VehicleQuery{RouteIDs: []string{"a"}}, []string{"2207 0 A  51.20,17.20 0 STOPPED_AT EMPTY"}},
+		{VehicleQuery{Area: &BoundingBox{51.1, 17.0, 51.15, 17.1}}, []string{"3301 32 33 PILCZYCE 51.12,17.03 90 STOPPED_AT FEW_SEATS_AVAILABLE"}},
+	}
+
+	for _, test := range tests {
+		vehicles, err := store.GetVehicles(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var result []string
+		for _, v := range vehicles {
+			result = append(result, fmt.Sprintf("%s %d %s %s %.2f,%.2f %.0f %s %s", v.VehicleID, v.TripID, v.RouteID, v.Headsign,
+				v.Latitude, v.Longitude, *v.Bearing, v.Status, v.Occupancy))
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, test.expected)
+		}
+	}
+
+	if vehicle, err := store.GetTripVehicle(32); err != nil || vehicle.VehicleID != "3301" {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, vehicle.VehicleID, "3301")
+	}
+	if _, err := store.GetTripVehicle(61); err != ErrVehicleNotFound {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, err, ErrVehicleNotFound)
+	}
+}
+
+func TestRealtimeTripTimeline(t *testing.T) {
+	// trip 61 of the previous service date, still running after midnight
+	yesterday := serviceDate(time.Now()).AddDate(0, 0, -1)
+	feed := testRealtimeFeed(time.Now(), testDatedTripUpdate("6_1", yesterday.Format("20060102"), false, 0, 60))
+	file, err := ioutil.TempFile("", "trip-updates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	if err := ioutil.WriteFile(file.Name(), feed, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	realtime := NewRealtime(file.Name(), time.Minute)
+	if err := realtime.Update(); err != nil {
+		t.Fatal(err)
+	}
+
+	timeline, err := WithRealtime(newTestMemoryStore(t), realtime, nil).GetTripTimeline(61, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prediction := timeline.Timeline[0].Prediction
+	if prediction == nil || prediction.Delay != 60 || !prediction.Time.Date.Equal(yesterday) {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, prediction, Prediction{ServiceTime{25*3600 + 660, yesterday}, 60, false})
+	}
+
+	// today's run has no updates
+	today := yesterday.AddDate(0, 0, 1)
+	timeline, err = WithRealtime(newTestMemoryStore(t), realtime, nil).GetTripTimeline(61, today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := timeline.Timeline[0]; entry.Prediction != nil || !entry.DepartureTime.Date.Equal(today) {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, entry, TripTimelineEntry{StopName: entry.StopName, DepartureTime: ServiceTime{25*3600 + 600, today}, StopID: 1})
+	}
+}
@@ -2,8 +2,8 @@ package GTFS
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/gorilla/mux"
-	bolt "github.com/johnnadratowski/golang-neo4j-bolt-driver"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -20,9 +20,9 @@ func wrapJSON(name string, item interface{}) ([]byte, error) {
 
 type Handler func(w http.ResponseWriter, r *http.Request)
 
-func StopsHandler(driver bolt.Driver) Handler {
+func StopsHandler(store Store) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
-		data, err := getAllStops(driver)
+		data, err := store.GetAllStops()
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -41,7 +41,7 @@ func StopsHandler(driver bolt.Driver) Handler {
 	}
 }
 
-func StopsAndRoutesHandler(driver bolt.Driver) Handler {
+func StopsAndRoutesHandler(store Store) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		type StopsAndRoutes struct {
@@ -49,13 +49,13 @@ func StopsAndRoutesHandler(driver bolt.Driver) Handler {
 			Routes []Route
 		}
 
-		stops, err := getAllStops(driver)
+		stops, err := store.GetAllStops()
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
 
-		routes, err := getAllRouteIDs(driver)
+		routes, err := store.GetAllRouteIDs()
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -71,9 +71,9 @@ func StopsAndRoutesHandler(driver bolt.Driver) Handler {
 	}
 }
 
-func RoutesHandler(driver bolt.Driver) Handler {
+func RoutesHandler(store Store) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
-		data, err := getAllRouteIDs(driver)
+		data, err := store.GetAllRouteIDs()
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -88,7 +88,7 @@ func RoutesHandler(driver bolt.Driver) Handler {
 	}
 }
 
-func RoutesVariantsByIdHandler(driver bolt.Driver) Handler {
+func RoutesVariantsByIdHandler(store Store) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		routeID, err := url.QueryUnescape(vars["routeID"])
@@ -97,7 +97,7 @@ func RoutesVariantsByIdHandler(driver bolt.Driver) Handler {
 			return
 		}
 
-		data, err := getRouteVariantsForRouteID(driver, routeID)
+		data, err := store.GetRouteVariants(routeID)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -111,7 +111,7 @@ func RoutesVariantsByIdHandler(driver bolt.Driver) Handler {
 	}
 }
 
-func RoutesVariantsByStopNameHandler(driver bolt.Driver) Handler {
+func RoutesVariantsByStopNameHandler(store Store) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		stopName, err := url.QueryUnescape(vars["stopName"])
@@ -120,7 +120,7 @@ func RoutesVariantsByStopNameHandler(driver bolt.Driver) Handler {
 			return
 		}
 
-		data, err := getRouteVariantsByStopName(driver, stopName)
+		data, err := store.GetRouteVariantsByStopName(stopName)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -139,7 +139,7 @@ func RoutesVariantsByStopNameHandler(driver bolt.Driver) Handler {
 	}
 }
 
-func RoutesTimeTableHandler(driver bolt.Driver) Handler {
+func RoutesTimeTableHandler(store Store) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		routeID, err := url.QueryUnescape(vars["routeID"])
@@ -160,7 +160,18 @@ func RoutesTimeTableHandler(driver bolt.Driver) Handler {
 			return
 		}
 
-		data, err := getTimetable(driver, routeID, stopName, direction)
+		var data interface{}
+		if dateString := r.URL.Query().Get("date"); dateString != "" {
+			var date time.Time
+			date, err = time.ParseInLocation("2006-01-02", dateString, warsaw)
+			if err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+			data, err = store.GetTimetableForDate(routeID, stopName, direction, date)
+		} else {
+			data, err = store.GetTimetable(routeID, stopName, direction)
+		}
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -179,7 +190,7 @@ func RoutesTimeTableHandler(driver bolt.Driver) Handler {
 	}
 }
 
-func RouteInfoHandler(driver bolt.Driver) Handler {
+func RouteInfoHandler(store Store) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		routeID, err := url.QueryUnescape(vars["routeID"])
@@ -188,7 +199,7 @@ func RouteInfoHandler(driver bolt.Driver) Handler {
 			return
 		}
 
-		data, err := getRouteInfo(driver, routeID)
+		data, err := store.GetRouteInfo(routeID)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -202,7 +213,7 @@ func RouteInfoHandler(driver bolt.Driver) Handler {
 	}
 }
 
-func RouteDirectionsHandler(driver bolt.Driver) Handler {
+func RouteDirectionsHandler(store Store) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		routeID, err := url.QueryUnescape(vars["routeID"])
@@ -211,7 +222,7 @@ func RouteDirectionsHandler(driver bolt.Driver) Handler {
 			return
 		}
 
-		data, err := getRouteDirections(driver, routeID)
+		data, err := store.GetRouteDirections(routeID)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -225,7 +236,7 @@ func RouteDirectionsHandler(driver bolt.Driver) Handler {
 	}
 }
 
-func RouteDirectionsThroughStopHandler(driver bolt.Driver) Handler {
+func RouteDirectionsThroughStopHandler(store Store) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		routeID, err := url.QueryUnescape(vars["routeID"])
@@ -240,7 +251,7 @@ func RouteDirectionsThroughStopHandler(driver bolt.Driver) Handler {
 			return
 		}
 
-		data, err := getRouteDirectionsThroughStop(driver, routeID, stopName)
+		data, err := store.GetRouteDirectionsThroughStop(routeID, stopName)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -254,7 +265,7 @@ func RouteDirectionsThroughStopHandler(driver bolt.Driver) Handler {
 	}
 }
 
-func RouteStopsHandler(driver bolt.Driver) Handler {
+func RouteStopsHandler(store Store) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		routeID, err := url.QueryUnescape(vars["routeID"])
@@ -263,7 +274,7 @@ func RouteStopsHandler(driver bolt.Driver) Handler {
 			return
 		}
 
-		data, err := getStopsForRouteID(driver, routeID)
+		data, err := store.GetStopsForRouteID(routeID)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -277,7 +288,7 @@ func RouteStopsHandler(driver bolt.Driver) Handler {
 	}
 }
 
-func TripTimelineHandler(driver bolt.Driver) Handler {
+func TripTimelineHandler(store Store) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		tripIDString, err := url.QueryUnescape(vars["tripID"])
@@ -292,21 +303,35 @@ func TripTimelineHandler(driver bolt.Driver) Handler {
 			return
 		}
 
-		data, err := getTripTimeline(driver, int(tripID))
+		// without a date, times refer to today's or the current run
+		var date time.Time
+		dateString := r.URL.Query().Get("date")
+		if dateString != "" {
+			date, err = time.ParseInLocation("2006-01-02", dateString, warsaw)
+			if err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+		}
+
+		data, err := store.GetTripTimeline(int(tripID), date)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
 
-		cacheUntil := time.Now().AddDate(0, 0, 1).Format(http.TimeFormat)
 		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Expires", cacheUntil)
+		if dateString != "" {
+			w.Header().Set("Expires", time.Now().AddDate(0, 0, 1).Format(http.TimeFormat))
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(data)
 	}
 }
 
-func RouteMapHandler(driver bolt.Driver) Handler {
+func RouteMapHandler(store Store) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		routeID, err := url.QueryUnescape(vars["routeID"])
@@ -327,7 +352,366 @@ func RouteMapHandler(driver bolt.Driver) Handler {
 			return
 		}
 
-		data, err := getMapData(driver, routeID, direction, stopName)
+		options, err := parseMapOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		data, err := store.GetMapData(routeID, direction, stopName)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		writeMapData(w, r, store, data, options)
+	}
+}
+
+// wantsGeoJSON tells if client asked for GeoJSON with Accept header or "format" parameter.
+func wantsGeoJSON(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "geojson"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/geo+json")
+}
+
+// parseMapOptions reads "tolerance" (metres), "zoom" (1-22) and "polyline" parameters.
+func parseMapOptions(r *http.Request) (MapOptions, error) {
+	params := r.URL.Query()
+	options := MapOptions{Polyline: params.Get("polyline") == "true"}
+
+	var err error
+	if tolerance := params.Get("tolerance"); tolerance != "" {
+		options.Tolerance, err = strconv.ParseFloat(tolerance, 64)
+		if err != nil || options.Tolerance < 0 {
+			return options, fmt.Errorf("tolerance must be a non-negative number of metres")
+		}
+	}
+	if zoom := params.Get("zoom"); zoom != "" {
+		options.Zoom, err = strconv.Atoi(zoom)
+		if err != nil || options.Zoom < 1 || options.Zoom > 22 {
+			return options, fmt.Errorf("zoom must be between 1 and 22")
+		}
+	}
+	return options, nil
+}
+
+// writeMapData writes map data as is, or as GeoJSON FeatureCollection coloured like its route if client wants it.
+// GeoJSON shapes are never encoded as polylines.
+func writeMapData(w http.ResponseWriter, r *http.Request, store Store, data MapData, options MapOptions) {
+	geoJSON := wantsGeoJSON(r)
+	if geoJSON {
+		options.Polyline = false
+	}
+	data = data.Apply(options)
+
+	var body interface{} = data
+	contentType := "application/json"
+	if geoJSON {
+		info, err := store.GetRouteInfo(data.RouteID)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		body = data.GeoJSON(info.Color)
+		contentType = "application/geo+json"
+	}
+
+	cacheUntil := time.Now().AddDate(0, 0, 1).Format(http.TimeFormat)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Expires", cacheUntil)
+	w.Header().Set("Vary", "Accept")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(body)
+}
+
+// splitParam splits comma separated query parameter, returning nil if it's missing.
+func splitParam(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// parseDepartureQuery reads optional query parameters of departures endpoint:
+// "at" (RFC3339), "window" (e.g. "90m"), "minutes", "limit", "routes", "headsigns" and "vehicle" ("tram" or "bus").
+func parseDepartureQuery(r *http.Request) (DepartureQuery, error) {
+	query := DepartureQuery{At: time.Now()}
+	params := r.URL.Query()
+
+	if at := params.Get("at"); at != "" {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return query, err
+		}
+		query.At = t
+	}
+
+	if window := params.Get("window"); window != "" {
+		d, err := time.ParseDuration(window)
+		if err != nil {
+			return query, err
+		}
+		if d < 0 {
+			return query, fmt.Errorf(`window "%s" is negative`, window)
+		}
+		query.Window = d
+	}
+
+	// "minutes" is a shorthand for window, the narrower one wins if both are given
+	if minutes := params.Get("minutes"); minutes != "" {
+		n, err := strconv.Atoi(minutes)
+		if err != nil || n <= 0 {
+			return query, fmt.Errorf(`invalid minutes "%s"`, minutes)
+		}
+		d := time.Duration(n) * time.Minute
+		if query.Window == 0 || d < query.Window {
+			query.Window = d
+		}
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return query, fmt.Errorf(`invalid limit "%s"`, limit)
+		}
+		query.Limit = n
+	}
+
+	query.RouteIDs = splitParam(params.Get("routes"))
+	query.Headsigns = splitParam(params.Get("headsigns"))
+
+	switch vehicle := params.Get("vehicle"); vehicle {
+	case "":
+	case "tram":
+		query.Vehicle = Tram
+	case "bus":
+		query.Vehicle = Bus
+	default:
+		return query, fmt.Errorf(`unknown vehicle "%s"`, vehicle)
+	}
+	return query, nil
+}
+
+// writeDepartures responds with departures from given stops, either grouped by stop or as a board.
+func writeDepartures(w http.ResponseWriter, r *http.Request, store Store, stopNames []string, query DepartureQuery) {
+	var data interface{}
+	var err error
+	if r.URL.Query().Get("board") == "true" {
+		data, err = store.GetDepartureBoard(stopNames, query)
+	} else {
+		data, err = store.GetUpcomingDepartures(stopNames, query)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(data)
+}
+
+func StopsUpcomingDeparturesHandler(store Store) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		stopNames, err := url.QueryUnescape(vars["stopNames"])
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		query, err := parseDepartureQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		writeDepartures(w, r, store, strings.Split(stopNames, ","), query)
+	}
+}
+
+// lookupError responds with 404 for unknown stops and stations and 500 for any other error.
+func lookupError(w http.ResponseWriter, err error) {
+	if err == ErrStopNotFound || err == ErrStationNotFound || err == ErrTripNotFound || err == ErrVehicleNotFound {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	if err == ErrNoRealtime {
+		http.Error(w, err.Error(), 503)
+		return
+	}
+	http.Error(w, err.Error(), 500)
+}
+
+func writeStop(w http.ResponseWriter, stop Stop) {
+	cacheUntil := time.Now().AddDate(0, 0, 1).Format(http.TimeFormat)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Expires", cacheUntil)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stop)
+}
+
+func StopByIDHandler(store Store) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		stopID, err := strconv.Atoi(vars["stopID"])
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		stop, err := store.GetStopByID(stopID)
+		if err != nil {
+			lookupError(w, err)
+			return
+		}
+		writeStop(w, stop)
+	}
+}
+
+func StopByCodeHandler(store Store) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		code, err := strconv.Atoi(vars["code"])
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		stop, err := store.GetStopByCode(code)
+		if err != nil {
+			lookupError(w, err)
+			return
+		}
+		writeStop(w, stop)
+	}
+}
+
+func StopsByIDUpcomingDeparturesHandler(store Store) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		query, err := parseDepartureQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		var stopNames []string
+		for _, value := range strings.Split(vars["stopIDs"], ",") {
+			stopID, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+
+			stop, err := store.GetStopByID(stopID)
+			if err != nil {
+				lookupError(w, err)
+				return
+			}
+			stopNames = append(stopNames, stop.Name)
+			query.StopIDs = append(query.StopIDs, stopID)
+		}
+
+		writeDepartures(w, r, store, stopNames, query)
+	}
+}
+
+const (
+	defaultNearbyRadius = 500.0
+	maxNearbyRadius     = 5000.0
+	defaultNearbyLimit  = 20
+)
+
+// floatParam parses query parameter, returning fallback if it's missing.
+func floatParam(r *http.Request, name string, fallback float64) (float64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf(`invalid %s "%s"`, name, value)
+	}
+	return f, nil
+}
+
+// StopsNearHandler lists stops within radius (metres) from point given by lat and lon, nearest first.
+func StopsNearHandler(store Store) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		if params.Get("lat") == "" || params.Get("lon") == "" {
+			http.Error(w, "lat and lon are required", 400)
+			return
+		}
+
+		lat, err := floatParam(r, "lat", 0)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		lon, err := floatParam(r, "lon", 0)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		radius, err := floatParam(r, "radius", defaultNearbyRadius)
+		if err != nil || radius <= 0 || radius > maxNearbyRadius {
+			http.Error(w, fmt.Sprintf("radius must be between 0 and %.0f metres", maxNearbyRadius), 400)
+			return
+		}
+		limit := defaultNearbyLimit
+		if value := params.Get("limit"); value != "" {
+			limit, err = strconv.Atoi(value)
+			if err != nil || limit < 1 {
+				http.Error(w, "limit must be positive", 400)
+				return
+			}
+		}
+
+		data, err := store.GetStopsNear(lat, lon, radius, limit)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		wrappedData, err := wrapJSON("stops", data)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		w.Write(wrappedData)
+	}
+}
+
+const defaultSearchLimit = 10
+
+// StopsSearchHandler finds stations by name given in q, e.g. "pl grunwaldzki".
+func StopsSearchHandler(store Store) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		limit := defaultSearchLimit
+		if value := params.Get("limit"); value != "" {
+			var err error
+			limit, err = strconv.Atoi(value)
+			if err != nil || limit < 1 {
+				http.Error(w, "limit must be positive", 400)
+				return
+			}
+		}
+
+		data, err := store.SearchStops(params.Get("q"), limit)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		wrappedData, err := wrapJSON("results", data)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -337,25 +721,244 @@ func RouteMapHandler(driver bolt.Driver) Handler {
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Expires", cacheUntil)
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(data)
+		w.Write(wrappedData)
 	}
 }
 
-func StopsUpcomingDeparturesHandler(driver bolt.Driver) Handler {
+// parseJourneyPlace reads stop ID (e.g. "1234") or coordinates (e.g. "51.1115,17.0606").
+func parseJourneyPlace(value string) (JourneyPlace, error) {
+	if value == "" {
+		return JourneyPlace{}, fmt.Errorf("missing origin or destination")
+	}
+
+	parts := strings.Split(value, ",")
+	switch len(parts) {
+	case 1:
+		stopID, err := strconv.Atoi(value)
+		if err == nil {
+			return JourneyPlace{StopID: stopID}, nil
+		}
+	case 2:
+		lat, latErr := strconv.ParseFloat(parts[0], 64)
+		lon, lonErr := strconv.ParseFloat(parts[1], 64)
+		if latErr == nil && lonErr == nil {
+			return JourneyPlace{Latitude: lat, Longitude: lon}, nil
+		}
+	}
+	return JourneyPlace{}, fmt.Errorf(`invalid place "%s", expected stop ID or "lat,lon"`, value)
+}
+
+// JourneysHandler plans journeys between "from" and "to" leaving after "at" (RFC3339, now by default),
+// or arriving before it if "arriveBy" is true.
+func JourneysHandler(store Store) Handler {
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		stopNames, err := url.QueryUnescape(vars["stopNames"])
+		params := r.URL.Query()
+		request := JourneyRequest{Time: time.Now(), ArriveBy: params.Get("arriveBy") == "true"}
+
+		var err error
+		if request.From, err = parseJourneyPlace(params.Get("from")); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if request.To, err = parseJourneyPlace(params.Get("to")); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if at := params.Get("at"); at != "" {
+			if request.Time, err = time.Parse(time.RFC3339, at); err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+		}
+		if count := params.Get("count"); count != "" {
+			request.Count, err = strconv.Atoi(count)
+			if err != nil || request.Count < 1 || request.Count > 10 {
+				http.Error(w, "count must be between 1 and 10", 400)
+				return
+			}
+		}
+
+		data, err := store.PlanJourneys(request)
+		if err == ErrNoAccess {
+			http.Error(w, err.Error(), 422)
+			return
+		} else if err != nil {
+			lookupError(w, err)
+			return
+		}
+		wrappedData, err := wrapJSON("journeys", data)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		w.Write(wrappedData)
+	}
+}
+
+// IsochroneHandler returns stops reachable from "from" within "minutes" when leaving at "at" (RFC3339, now by default).
+// Reachable area is included if "polygon" is true.
+func IsochroneHandler(store Store) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		request := IsochroneRequest{Time: time.Now(), Area: params.Get("polygon") == "true"}
+
+		var err error
+		if request.From, err = parseJourneyPlace(params.Get("from")); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if at := params.Get("at"); at != "" {
+			if request.Time, err = time.Parse(time.RFC3339, at); err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+		}
+		minutes, err := strconv.Atoi(params.Get("minutes"))
+		if err != nil || minutes < 1 || minutes > 120 {
+			http.Error(w, "minutes must be between 1 and 120", 400)
+			return
+		}
+		request.Duration = time.Duration(minutes) * time.Minute
+
+		data, err := store.GetIsochrone(request)
+		if err == ErrNoAccess {
+			http.Error(w, err.Error(), 422)
+			return
+		} else if err != nil {
+			lookupError(w, err)
+			return
+		}
+		wrappedData, err := wrapJSON("isochrone", data)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		w.Write(wrappedData)
+	}
+}
+
+// parseBoundingBox reads "west,south,east,north" in degrees, returning nil if value is empty.
+func parseBoundingBox(value string) (*BoundingBox, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf(`invalid bbox "%s", expected "west,south,east,north"`, value)
+	}
+	var coordinates [4]float64
+	for i, part := range parts {
+		coordinate, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid bbox "%s", expected "west,south,east,north"`, value)
+		}
+		coordinates[i] = coordinate
+	}
+	return &BoundingBox{coordinates[1], coordinates[0], coordinates[3], coordinates[2]}, nil
+}
+
+// ScheduledVehiclesHandler returns where vehicles should be at "at" (RFC3339, now by default) according to timetable,
+// within "bbox" and on comma separated "route"s if given.
+func ScheduledVehiclesHandler(store Store) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		query := VehicleQuery{At: time.Now(), RouteIDs: splitParam(params.Get("route"))}
+
+		var err error
+		if at := params.Get("at"); at != "" {
+			if query.At, err = time.Parse(time.RFC3339, at); err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+		}
+		if query.Area, err = parseBoundingBox(params.Get("bbox")); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		data, err := store.GetScheduledVehicles(query)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
+		wrappedData, err := wrapJSON("vehicles", data)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		w.Write(wrappedData)
+	}
+}
+
+// VehiclesHandler returns vehicles reported by realtime feed, on given comma separated "route"s and within "bbox".
+// Without a source of vehicles it responds with 503.
+func VehiclesHandler(source VehicleSource) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if source == nil {
+			lookupError(w, ErrNoRealtime)
+			return
+		}
+
+		params := r.URL.Query()
+		query := VehicleQuery{RouteIDs: splitParam(params.Get("route"))}
+
+		var err error
+		if query.Area, err = parseBoundingBox(params.Get("bbox")); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
 
-		data, err := getUpcomingDepartures(driver, strings.Split(stopNames, ","))
+		data, err := source.GetVehicles(query)
+		if err != nil {
+			lookupError(w, err)
+			return
+		}
+		wrappedData, err := wrapJSON("vehicles", data)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
 
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		w.Write(wrappedData)
+	}
+}
+
+func TripVehicleHandler(source VehicleSource) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if source == nil {
+			lookupError(w, ErrNoRealtime)
+			return
+		}
+
+		vars := mux.Vars(r)
+		tripID, err := parseTripID(vars["tripID"])
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		data, err := source.GetTripVehicle(tripID)
+		if err != nil {
+			lookupError(w, err)
+			return
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Cache-Control", "no-cache")
 		w.WriteHeader(http.StatusOK)
@@ -363,17 +966,74 @@ func StopsUpcomingDeparturesHandler(driver bolt.Driver) Handler {
 	}
 }
 
-func TripMapHandler(driver bolt.Driver) Handler {
+// TilesHandler serves Mapbox Vector Tiles of the whole network. Tiles are cached until feed version changes.
+func TilesHandler(store Store) Handler {
+	cache := newTileCache()
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
-		tripIDString, err := url.QueryUnescape(vars["tripID"])
+		var coordinates [3]int
+		for i, name := range []string{"z", "x", "y"} {
+			value, err := strconv.Atoi(vars[name])
+			if err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+			coordinates[i] = value
+		}
+
+		tile, err := cache.tile(store, coordinates[0], coordinates[1], coordinates[2])
+		if err == errNoSuchTile {
+			http.Error(w, err.Error(), 404)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		cacheUntil := time.Now().AddDate(0, 0, 1).Format(http.TimeFormat)
+		w.Header().Set("Content-Type", "application/vnd.mapbox-vector-tile")
+		w.Header().Set("Expires", cacheUntil)
+		w.WriteHeader(http.StatusOK)
+		w.Write(tile)
+	}
+}
+
+func StopTransfersHandler(store Store) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		stopID, err := strconv.Atoi(vars["stopID"])
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		data, err := store.GetTransfers(stopID)
+		if err != nil {
+			lookupError(w, err)
+			return
+		}
+		wrappedData, err := wrapJSON("transfers", data)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
 
-		tripID, err := strconv.ParseInt(tripIDString, 10, 32)
-		data, err := getMapDataForTripID(driver, int(tripID))
+		cacheUntil := time.Now().AddDate(0, 0, 1).Format(http.TimeFormat)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Expires", cacheUntil)
+		w.WriteHeader(http.StatusOK)
+		w.Write(wrappedData)
+	}
+}
+
+func StationsHandler(store Store) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := store.GetStations()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		wrappedData, err := wrapJSON("stations", data)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -383,6 +1043,208 @@ func TripMapHandler(driver bolt.Driver) Handler {
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Expires", cacheUntil)
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(data)
+		w.Write(wrappedData)
+	}
+}
+
+func StationHandler(store Store) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		stationID, err := strconv.Atoi(vars["stationID"])
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		station, err := store.GetStation(stationID)
+		if err != nil {
+			lookupError(w, err)
+			return
+		}
+
+		cacheUntil := time.Now().AddDate(0, 0, 1).Format(http.TimeFormat)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Expires", cacheUntil)
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(station)
+	}
+}
+
+// StationUpcomingDeparturesHandler lists departures from all platforms of the station.
+func StationUpcomingDeparturesHandler(store Store) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		stationID, err := strconv.Atoi(vars["stationID"])
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		query, err := parseDepartureQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		station, err := store.GetStation(stationID)
+		if err != nil {
+			lookupError(w, err)
+			return
+		}
+
+		// a name can be shared by distant stations, so departures are limited to this station's platforms
+		for _, platform := range station.Platforms {
+			query.StopIDs = append(query.StopIDs, platform.ID)
+		}
+		writeDepartures(w, r, store, []string{station.Name}, query)
+	}
+}
+
+func RoutesVariantsByStopIDHandler(store Store) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		stopID, err := strconv.Atoi(vars["stopID"])
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		data, err := store.GetRouteVariantsByStopID(stopID)
+		if err != nil {
+			lookupError(w, err)
+			return
+		}
+		wrappedData, err := wrapJSON("routeVariants", data)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		cacheUntil := time.Now().AddDate(0, 0, 1).Format(http.TimeFormat)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Expires", cacheUntil)
+		w.WriteHeader(http.StatusOK)
+		w.Write(wrappedData)
+	}
+}
+
+func RoutesTimeTableAtStopHandler(store Store) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		routeID, err := url.QueryUnescape(vars["routeID"])
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		stopID, err := strconv.Atoi(vars["stopID"])
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		direction, err := url.QueryUnescape(vars["direction"])
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		var data interface{}
+		if dateString := r.URL.Query().Get("date"); dateString != "" {
+			var date time.Time
+			date, err = time.ParseInLocation("2006-01-02", dateString, warsaw)
+			if err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+			data, err = store.GetTimetableAtStopForDate(routeID, stopID, direction, date)
+		} else {
+			data, err = store.GetTimetableAtStop(routeID, stopID, direction)
+		}
+		if err != nil {
+			lookupError(w, err)
+			return
+		}
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		cacheUntil := time.Now().AddDate(0, 0, 1).Format(http.TimeFormat)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Expires", cacheUntil)
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonData)
+	}
+}
+
+func TripMapHandler(store Store) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		tripIDString, err := url.QueryUnescape(vars["tripID"])
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		tripID, err := strconv.ParseInt(tripIDString, 10, 32)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		options, err := parseMapOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		data, err := store.GetMapDataForTripID(int(tripID))
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		writeMapData(w, r, store, data, options)
+	}
+}
+
+// AlertsHandler serves alerts as a GTFS-Realtime feed, or as JSON for debugging when format is json.
+// Alerts without routes apply to all agencies in the store.
+func AlertsHandler(store Store, source AlertSource) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		alerts, err := source(now)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		agencyIDs, err := store.GetAgencyIDs()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		withAgencies(alerts, agencyIDs)
+
+		if mux.Vars(r)["format"] == "json" {
+			wrappedData, err := wrapJSON("alerts", alerts)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.WriteHeader(http.StatusOK)
+			w.Write(wrappedData)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		w.Write(encodeAlerts(alerts, now))
 	}
 }
@@ -0,0 +1,270 @@
+package GTFS
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeStore implements only the methods used by tests, calling anything else panics.
+type fakeStore struct {
+	Store
+	stops []Stop
+	err   error
+}
+
+func (store *fakeStore) GetAllStops() ([]Stop, error) {
+	return store.stops, store.err
+}
+
+func (store *fakeStore) GetStopByID(stopID int) (Stop, error) {
+	for _, stop := range store.stops {
+		if stop.ID == stopID {
+			return stop, nil
+		}
+	}
+	return Stop{}, ErrStopNotFound
+}
+
+func (store *fakeStore) GetRouteInfo(routeID string) (RouteInfo, error) {
+	return RouteInfo{RouteID: routeID}, store.err
+}
+
+func (store *fakeStore) GetTimetable(routeID, stopName, direction string) (TimeTable, error) {
+	return TimeTable{RouteID: routeID}, store.err
+}
+
+func (store *fakeStore) GetTimetableForDate(routeID, stopName, direction string, date time.Time) (DateTimeTable, error) {
+	return DateTimeTable{RouteID: routeID}, store.err
+}
+
+func TestStopsHandler(t *testing.T) {
+	store := &fakeStore{stops: []Stop{{"Plac Grunwaldzki", 1, 51.11, 17.06, 20001}}}
+
+	w := httptest.NewRecorder()
+	StopsHandler(store)(w, httptest.NewRequest("GET", "/stops", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Wrong status code: %d", w.Code)
+	}
+
+	var data map[string][]Stop
+	if err := json.NewDecoder(w.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data["stops"]) != 1 || data["stops"][0] != store.stops[0] {
+		t.Errorf("Wrong result: %v", data)
+	}
+}
+
+func TestRouteInfoHandler(t *testing.T) {
+	router := mux.NewRouter().UseEncodedPath()
+	store := &fakeStore{}
+	router.HandleFunc("/route/{routeID}/info", RouteInfoHandler(store))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/route/A/info", nil))
+
+	var data RouteInfo
+	if err := json.NewDecoder(w.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if data.RouteID != "A" {
+		t.Errorf(`Wrong route ID. Got "%s", expected "A"`, data.RouteID)
+	}
+
+	store.err = errors.New("connection refused")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/route/A/info", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Wrong status code: %d", w.Code)
+	}
+}
+
+func TestRoutesTimeTableHandler(t *testing.T) {
+	store := &fakeStore{}
+	router := mux.NewRouter().UseEncodedPath()
+	router.HandleFunc("/route/{routeID}/timetable/at/{stopName}/direction/{direction}", RoutesTimeTableHandler(store))
+
+	tests := []struct {
+		path string
+		err  error
+		code int
+	}{
+		{"/route/A/timetable/at/Rynek/direction/Kromera", nil, http.StatusOK},
+		{"/route/A/timetable/at/Rynek/direction/Kromera?date=2018-10-27", nil, http.StatusOK},
+		{"/route/A/timetable/at/Rynek/direction/Kromera?date=27.10.2018", nil, http.StatusBadRequest},
+		{"/route/A/timetable/at/Rynek/direction/Kromera", errors.New("connection refused"), http.StatusInternalServerError},
+		{"/route/A/timetable/at/Rynek/direction/Kromera?date=2018-10-27", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		store.err = test.err
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.code {
+			t.Errorf(`Wrong status code for "%s". Got %d, expected: %d`, test.path, w.Code, test.code)
+		}
+	}
+}
+
+func TestRoutesTimeTableAtStopHandler(t *testing.T) {
+	router := mux.NewRouter().UseEncodedPath()
+	router.HandleFunc("/route/{routeID}/timetable/at/id/{stopID}/direction/{direction}", RoutesTimeTableAtStopHandler(newTestMemoryStore(t)))
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/route/33/timetable/at/id/1/direction/PILCZYCE", http.StatusOK},
+		{"/route/33/timetable/at/id/1/direction/PILCZYCE?date=2018-10-27", http.StatusOK},
+		{"/route/33/timetable/at/id/9/direction/PILCZYCE", http.StatusNotFound},
+		{"/route/33/timetable/at/id/9/direction/PILCZYCE?date=2018-10-27", http.StatusNotFound},
+		{"/route/33/timetable/at/id/1/direction/PILCZYCE?date=sobota", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.code {
+			t.Errorf(`Wrong status code for "%s". Got %d, expected: %d`, test.path, w.Code, test.code)
+		}
+	}
+}
+
+func TestRoutesVariantsByStopIDHandler(t *testing.T) {
+	router := mux.NewRouter().UseEncodedPath()
+	router.HandleFunc("/routes/variants/stop/id/{stopID}", RoutesVariantsByStopIDHandler(newTestMemoryStore(t)))
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/routes/variants/stop/id/1", http.StatusOK},
+		{"/routes/variants/stop/id/9", http.StatusNotFound},
+		{"/routes/variants/stop/id/plac", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.code {
+			t.Errorf(`Wrong status code for "%s". Got %d, expected: %d`, test.path, w.Code, test.code)
+		}
+	}
+}
+
+func TestParseDepartureQuery(t *testing.T) {
+	tests := []struct {
+		params   string
+		expected DepartureQuery
+		valid    bool
+	}{
+		{"?limit=10&routes=33,D&vehicle=tram", DepartureQuery{Limit: 10, RouteIDs: []string{"33", "D"}, Vehicle: Tram}, true},
+		{"?window=2h&minutes=30", DepartureQuery{Window: 30 * time.Minute}, true},
+		{"?headsigns=PILCZYCE", DepartureQuery{Headsigns: []string{"PILCZYCE"}}, true},
+		{"?limit=0", DepartureQuery{}, false},
+		{"?vehicle=ship", DepartureQuery{}, false},
+		{"?at=tomorrow", DepartureQuery{}, false},
+	}
+
+	for _, test := range tests {
+		query, err := parseDepartureQuery(httptest.NewRequest("GET", "/stops/a/departures"+test.params, nil))
+		if (err == nil) != test.valid {
+			t.Errorf(`Wrong result for "%s": %v`, test.params, err)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		query.At = time.Time{}
+		if !reflect.DeepEqual(query, test.expected) {
+			t.Errorf(`Wrong result. Got "%+v", expected: "%+v"`, query, test.expected)
+		}
+	}
+}
+
+func TestStopByIDHandler(t *testing.T) {
+	store := &fakeStore{stops: []Stop{{"Plac Grunwaldzki", 1, 51.11, 17.06, 20001}}}
+	router := mux.NewRouter()
+	router.HandleFunc("/stops/id/{stopID}", StopByIDHandler(store))
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/stops/id/1", http.StatusOK},
+		{"/stops/id/2", http.StatusNotFound},
+		{"/stops/id/abc", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.code {
+			t.Errorf(`Wrong status code for "%s". Got %d, expected: %d`, test.path, w.Code, test.code)
+		}
+	}
+}
+
+func TestTripMapHandlerGeoJSON(t *testing.T) {
+	router := mux.NewRouter().UseEncodedPath()
+	router.HandleFunc("/trip/{tripID}/map", TripMapHandler(newTestMemoryStore(t)))
+
+	tests := []struct {
+		url         string
+		accept      string
+		contentType string
+	}{
+		{"/trip/61/map", "", "application/json"},
+		{"/trip/61/map", "application/geo+json", "application/geo+json"},
+		{"/trip/61/map?format=geojson", "", "application/geo+json"},
+		{"/trip/61/map?format=json", "application/geo+json", "application/json"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.url, nil)
+		r.Header.Set("Accept", test.accept)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if result := w.Header().Get("Content-Type"); result != test.contentType {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, test.contentType)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/trip/61/map?format=geojson", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	var data GeoJSONFeatureCollection
+	if err := json.NewDecoder(w.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+
+	var result []string
+	for _, feature := range data.Features {
+		result = append(result, fmt.Sprintf("%s %v %v %v", feature.Geometry.Type, feature.Properties["Color"],
+			feature.Properties["OnDemand"], feature.Properties["FirstOrLast"]))
+	}
+	// stops aren't ordered
+	sort.Strings(result)
+	expected := []string{"LineString #D2232A <nil> <nil>", "Point #D2232A false true", "Point #D2232A true true"}
+	if data.Type != "FeatureCollection" || !reflect.DeepEqual(result, expected) {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, expected)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/trip/abc/map", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf(`Wrong status code for "/trip/abc/map". Got %d, expected: %d`, w.Code, http.StatusBadRequest)
+	}
+}
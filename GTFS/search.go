@@ -0,0 +1,193 @@
+package GTFS
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var diacritics = strings.NewReplacer(
+	"ą", "a", "ć", "c", "ę", "e", "ł", "l", "ń", "n", "ó", "o", "ś", "s", "ź", "z", "ż", "z",
+)
+
+// abbreviations used in stop names. names are indexed under both forms,
+// query words are left as typed, so that "pl" can still be a prefix of e.g. "Plebiscytowa".
+var abbreviations = map[string]string{
+	"pl":  "plac",
+	"ul":  "ulica",
+	"os":  "osiedle",
+	"al":  "aleja",
+	"dw":  "dworzec",
+	"sw":  "swietego",
+	"gl":  "glowny",
+	"pd":  "poludniowy",
+	"pn":  "polnocny",
+	"zaj": "zajezdnia",
+}
+
+// searchTokens lowercases text, strips Polish diacritics and splits it into words.
+func searchTokens(text string) []string {
+	text = diacritics.Replace(strings.ToLower(text))
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// nameTokens returns search tokens of stop name together with expanded abbreviations.
+func nameTokens(name string) []string {
+	words := searchTokens(name)
+	for _, word := range words {
+		if expanded, ok := abbreviations[word]; ok {
+			words = append(words, expanded)
+		}
+	}
+	return words
+}
+
+// levenshtein returns edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+// allowedTypos grows with word length, so that short words have to be typed correctly
+func allowedTypos(word string) int {
+	switch n := len([]rune(word)); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	}
+	return 0
+}
+
+// tokenScore tells how well query word matches name word: 3 for equal words, 2 for prefix,
+// 1 for a word with a few typos (or its prefix with typos) and 0 if they don't match.
+func tokenScore(query, word string) int {
+	if query == word {
+		return 3
+	}
+	if strings.HasPrefix(word, query) {
+		return 2
+	}
+
+	typos := allowedTypos(query)
+	if typos == 0 {
+		return 0
+	}
+	if levenshtein(query, word) <= typos {
+		return 1
+	}
+	// typo in a prefix typed so far
+	wordRunes := []rune(word)
+	if n := len([]rune(query)); n < len(wordRunes) && levenshtein(query, string(wordRunes[:n])) <= typos {
+		return 1
+	}
+	return 0
+}
+
+// matchScore returns sum of best scores of all query words, or zero if any of them doesn't match the name.
+func matchScore(query, name []string) int {
+	total := 0
+	for _, q := range query {
+		best := 0
+		for _, word := range name {
+			if score := tokenScore(q, word); score > best {
+				best = score
+			}
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return total
+}
+
+type StopSearchResult struct {
+	Station
+	Routes []string
+	// higher is better
+	Score int
+}
+
+type searchEntry struct {
+	station Station
+	tokens  []string
+	routes  []string
+}
+
+func newSearchEntries(stations []Station, routes map[int][]string) []searchEntry {
+	entries := make([]searchEntry, len(stations))
+	for idx, station := range stations {
+		seen := map[string]bool{}
+		stationRoutes := []string{}
+		for _, platform := range station.Platforms {
+			for _, routeID := range routes[platform.ID] {
+				if !seen[routeID] {
+					seen[routeID] = true
+					stationRoutes = append(stationRoutes, routeID)
+				}
+			}
+		}
+		sort.Strings(stationRoutes)
+		entries[idx] = searchEntry{station, nameTokens(station.Name), stationRoutes}
+	}
+	return entries
+}
+
+// search finds stations matching the query, best matches and stations served by more routes first.
+func (index *stopIndex) search(query string, limit int) []StopSearchResult {
+	tokens := searchTokens(query)
+	results := []StopSearchResult{}
+	if len(tokens) == 0 {
+		return results
+	}
+
+	for _, entry := range index.searchEntries {
+		if score := matchScore(tokens, entry.tokens); score > 0 {
+			results = append(results, StopSearchResult{entry.station, entry.routes, score})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if len(results[i].Routes) != len(results[j].Routes) {
+			return len(results[i].Routes) > len(results[j].Routes)
+		}
+		return results[i].Name < results[j].Name
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
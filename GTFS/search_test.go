@@ -0,0 +1,64 @@
+package GTFS
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchTokens(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"Dworzec Główny", []string{"dworzec", "glowny"}},
+		{"pl. Grunwaldzki", []string{"pl", "grunwaldzki"}},
+		{"ŻERNIKI (Zajezdnia)", []string{"zerniki", "zajezdnia"}},
+	}
+
+	for _, test := range tests {
+		result := searchTokens(test.text)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, test.expected)
+		}
+	}
+}
+
+func TestSearchStops(t *testing.T) {
+	stops := []Stop{
+		{"PL. GRUNWALDZKI", 1, 51.1115, 17.0606, 0},
+		{"PLEBISCYTOWA", 2, 51.1000, 17.0000, 0},
+		{"DWORZEC GŁÓWNY", 3, 51.0990, 17.0360, 0},
+		{"DWORZEC AUTOBUSOWY", 4, 51.0970, 17.0340, 0},
+		{"OS. SOBIESKIEGO", 5, 51.1500, 17.1000, 0},
+	}
+	routes := map[int][]string{
+		3: {"2", "8", "9", "11", "D"},
+		4: {"A"},
+	}
+	index := newStopIndex(stops, routes)
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"pl grunwaldzki", []string{"PL. GRUNWALDZKI"}},
+		{"plac grunw", []string{"PL. GRUNWALDZKI"}},
+		{"pl", []string{"PL. GRUNWALDZKI", "PLEBISCYTOWA"}},
+		{"Dworzec Glowny", []string{"DWORZEC GŁÓWNY"}},
+		// more routes first
+		{"dworzec", []string{"DWORZEC GŁÓWNY", "DWORZEC AUTOBUSOWY"}},
+		{"dwrzec glowny", []string{"DWORZEC GŁÓWNY"}},
+		{"osiedle sobieskiego", []string{"OS. SOBIESKIEGO"}},
+		{"xyz", nil},
+	}
+
+	for _, test := range tests {
+		var result []string
+		for _, station := range index.search(test.query, 10) {
+			result = append(result, station.Name)
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf(`Wrong result for "%s". Got "%v", expected: "%v"`, test.query, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package GTFS
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServiceTime is a time of departure or arrival, counted in seconds from the start of the service day.
+// Trips running after midnight belong to the previous service day, so their times go past 24:00.
+// Date is the service date; it's zero when time isn't bound to any particular day (e.g. in weekly timetables).
+type ServiceTime struct {
+	Seconds int
+	Date    time.Time
+}
+
+// ParseServiceTime parses GTFS time in "HH:MM" or "HH:MM:SS" format. Hours can exceed 23.
+func ParseServiceTime(value string) (int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return 0, fmt.Errorf(`invalid time "%s"`, value)
+	}
+
+	seconds := 0
+	multipliers := []int{3600, 60, 1}
+	for idx, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (idx > 0 && n > 59) {
+			return 0, fmt.Errorf(`invalid time "%s"`, value)
+		}
+		seconds += n * multipliers[idx]
+	}
+	return seconds, nil
+}
+
+// newServiceTime is used with times read from the database, which were validated during import.
+func newServiceTime(value string) ServiceTime {
+	seconds, _ := ParseServiceTime(value)
+	return ServiceTime{Seconds: seconds}
+}
+
+// serviceDate returns midnight of given day in Wrocław.
+func serviceDate(t time.Time) time.Time {
+	t = t.In(warsaw)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, warsaw)
+}
+
+// On returns the same time bound to given service date.
+func (t ServiceTime) On(date time.Time) ServiceTime {
+	return ServiceTime{t.Seconds, serviceDate(date)}
+}
+
+// Display returns wall clock time, e.g. "01:10" for 25:10.
+func (t ServiceTime) Display() string {
+	seconds := t.Seconds % (24 * 3600)
+	return fmt.Sprintf("%02d:%02d", seconds/3600, seconds%3600/60)
+}
+
+// Time returns absolute point in time. Following GTFS, service day starts 12 hours before noon,
+// which keeps times correct on days when clocks change.
+func (t ServiceTime) Time() time.Time {
+	noon := time.Date(t.Date.Year(), t.Date.Month(), t.Date.Day(), 12, 0, 0, 0, warsaw)
+	return noon.Add(time.Duration(t.Seconds-12*3600) * time.Second)
+}
+
+func (t ServiceTime) MarshalJSON() ([]byte, error) {
+	data := struct {
+		Time      string
+		Seconds   int
+		Timestamp *time.Time
+	}{t.Display(), t.Seconds, nil}
+
+	if !t.Date.IsZero() {
+		timestamp := t.Time()
+		data.Timestamp = &timestamp
+	}
+	return json.Marshal(data)
+}
@@ -0,0 +1,111 @@
+package GTFS
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseServiceTime(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+		valid    bool
+	}{
+		{"10:00", 36000, true},
+		{"7:05:30", 25530, true},
+		{"25:10:00", 90600, true},
+		{"10:60", 0, false},
+		{"10", 0, false},
+		{"ab:cd", 0, false},
+	}
+
+	for _, test := range tests {
+		seconds, err := ParseServiceTime(test.value)
+		if (err == nil) != test.valid || seconds != test.expected {
+			t.Errorf(`Wrong result for "%s". Got %d (%v), expected: %d`, test.value, seconds, err, test.expected)
+		}
+	}
+}
+
+func TestServiceTimeDisplay(t *testing.T) {
+	tests := []struct {
+		seconds  int
+		expected string
+	}{
+		{0, "00:00"},
+		{10*3600 + 5*60 + 30, "10:05"},
+		{25*3600 + 10*60, "01:10"},
+	}
+
+	for _, test := range tests {
+		result := ServiceTime{Seconds: test.seconds}.Display()
+		if result != test.expected {
+			t.Errorf(`Wrong result. Got "%s", expected: "%s"`, result, test.expected)
+		}
+	}
+}
+
+func TestServiceTimeTime(t *testing.T) {
+	tests := []struct {
+		date     time.Time
+		seconds  int
+		expected time.Time
+	}{
+		// after midnight, on the next calendar day
+		{time.Date(2018, 5, 10, 0, 0, 0, 0, warsaw), 25*3600 + 10*60, time.Date(2018, 5, 11, 1, 10, 0, 0, warsaw)},
+		// clocks go back at 3:00 on the last Sunday of October
+		{time.Date(2018, 10, 28, 0, 0, 0, 0, warsaw), 10 * 3600, time.Date(2018, 10, 28, 10, 0, 0, 0, warsaw)},
+		// clocks go forward at 2:00 on the last Sunday of March
+		{time.Date(2018, 3, 25, 0, 0, 0, 0, warsaw), 23 * 3600, time.Date(2018, 3, 25, 23, 0, 0, 0, warsaw)},
+	}
+
+	for _, test := range tests {
+		result := ServiceTime{Seconds: test.seconds}.On(test.date).Time()
+		if !result.Equal(test.expected) {
+			t.Errorf(`Wrong result. Got "%s", expected: "%s"`, result, test.expected)
+		}
+	}
+}
+
+func TestServiceTimeJSON(t *testing.T) {
+	date := time.Date(2018, 5, 10, 0, 0, 0, 0, warsaw)
+	tests := []struct {
+		time     ServiceTime
+		expected string
+	}{
+		{ServiceTime{Seconds: 25*3600 + 600}, `{"Time":"01:10","Seconds":90600,"Timestamp":null}`},
+		{ServiceTime{Seconds: 25*3600 + 600}.On(date), `{"Time":"01:10","Seconds":90600,"Timestamp":"2018-05-11T01:10:00+02:00"}`},
+	}
+
+	for _, test := range tests {
+		result, err := json.Marshal(test.time)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(result) != test.expected {
+			t.Errorf(`Wrong result. Got "%s", expected: "%s"`, result, test.expected)
+		}
+	}
+}
+
+func TestFilterDeparturesAfterMidnight(t *testing.T) {
+	calendar := NewServiceCalendar([]FeedCalendar{
+		{ServiceID: 6, Weekdays: [7]bool{false, true, true, true, true, false, false}, StartDate: "20180101", EndDate: "20181231"},
+	}, nil, nil)
+	departures := []UpcomingDeparture{
+		{TripID: 1, DepartureTime: ServiceTime{Seconds: 23*3600 + 50*60}, RouteID: "33", ServiceID: 6},
+		{TripID: 2, DepartureTime: ServiceTime{Seconds: 25*3600 + 10*60}, RouteID: "33", ServiceID: 6},
+	}
+
+	// Friday 00:30 - trip after midnight still belongs to Thursday's service
+	now := time.Date(2018, 5, 11, 0, 30, 0, 0, warsaw)
+	result := filterDepartures(departures, calendar, DepartureQuery{At: now})
+	if len(result) != 1 || result[0].TripID != 2 {
+		t.Fatalf("Wrong departures: %v", result)
+	}
+	expected := time.Date(2018, 5, 11, 1, 10, 0, 0, warsaw)
+	if !result[0].DepartureTime.Time().Equal(expected) {
+		t.Errorf(`Wrong result. Got "%s", expected: "%s"`, result[0].DepartureTime.Time(), expected)
+	}
+}
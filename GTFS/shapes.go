@@ -0,0 +1,152 @@
+package GTFS
+
+import (
+	"math"
+	"strings"
+)
+
+// size of a tile pixel at zoom 0 on the equator, in metres
+const equatorPixelSize = 2 * math.Pi * earthRadius / 256
+
+// MapOptions tell how to shrink shapes sent to clients.
+type MapOptions struct {
+	// metres, zero keeps all points
+	Tolerance float64
+	// map zoom level the shapes are simplified for if Tolerance isn't set, zero if not given
+	Zoom int
+	// send shapes as encoded polylines instead of points
+	Polyline bool
+}
+
+// zoomTolerance returns size of a map pixel at given zoom and latitude, in metres
+func zoomTolerance(zoom int, latitude float64) float64 {
+	return equatorPixelSize * math.Cos(latitude*math.Pi/180) / math.Pow(2, float64(zoom))
+}
+
+// Apply simplifies shapes, keeping points closest to stops, and encodes them if asked to.
+func (data MapData) Apply(options MapOptions) MapData {
+	result := MapData{RouteID: data.RouteID, Stops: data.Stops}
+	for _, shape := range data.Shapes {
+		tolerance := options.Tolerance
+		if tolerance == 0 && options.Zoom > 0 && len(shape.Points) > 0 {
+			tolerance = zoomTolerance(options.Zoom, float64(shape.Points[0].Latitude))
+		}
+		if tolerance > 0 {
+			shape.Points = simplifyShape(shape.Points, data.Stops, tolerance)
+		}
+		if options.Polyline {
+			shape.Polyline = encodePolyline(shape.Points)
+			shape.Points = nil
+		}
+		result.Shapes = append(result.Shapes, shape)
+	}
+	return result
+}
+
+// planar returns point's position in metres, good enough for distances within a city
+func planar(latitude, longitude, refLatitude float64) (float64, float64) {
+	const metresPerDegree = earthRadius * math.Pi / 180
+	return longitude * metresPerDegree * math.Cos(refLatitude*math.Pi/180), latitude * metresPerDegree
+}
+
+// simplifyShape removes points with Douglas-Peucker algorithm. Points closest to stops are always kept,
+// so that stops stay on the line.
+func simplifyShape(points ShapePoints, stops []StopOnMap, tolerance float64) ShapePoints {
+	if len(points) < 3 {
+		return points
+	}
+
+	refLatitude := float64(points[0].Latitude)
+	xs, ys := make([]float64, len(points)), make([]float64, len(points))
+	for i, point := range points {
+		xs[i], ys[i] = planar(float64(point.Latitude), float64(point.Longitude), refLatitude)
+	}
+
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	for _, stop := range stops {
+		x, y := planar(stop.Latitude, stop.Longitude, refLatitude)
+		closest, closestDistance := 0, math.Inf(1)
+		for i := range points {
+			if d := math.Hypot(xs[i]-x, ys[i]-y); d < closestDistance {
+				closest, closestDistance = i, d
+			}
+		}
+		keep[closest] = true
+	}
+
+	// simplify between every pair of kept points
+	var simplify func(first, last int)
+	simplify = func(first, last int) {
+		farthest, farthestDistance := -1, tolerance
+		for i := first + 1; i < last; i++ {
+			if d := segmentDistance(xs[i], ys[i], xs[first], ys[first], xs[last], ys[last]); d > farthestDistance {
+				farthest, farthestDistance = i, d
+			}
+		}
+		if farthest >= 0 {
+			keep[farthest] = true
+			simplify(first, farthest)
+			simplify(farthest, last)
+		}
+	}
+	first := 0
+	for i := 1; i < len(points); i++ {
+		if keep[i] {
+			simplify(first, i)
+			first = i
+		}
+	}
+
+	var result ShapePoints
+	for i, point := range points {
+		if keep[i] {
+			result = append(result, point)
+		}
+	}
+	return result
+}
+
+// segmentDistance returns distance between point (x, y) and segment from (x1, y1) to (x2, y2)
+func segmentDistance(x, y, x1, y1, x2, y2 float64) float64 {
+	_, d := segmentProjection(x, y, x1, y1, x2, y2)
+	return d
+}
+
+// segmentProjection returns position of point (x, y) projected onto segment, as a fraction of its length,
+// and distance between the point and its projection.
+func segmentProjection(x, y, x1, y1, x2, y2 float64) (float64, float64) {
+	dx, dy := x2-x1, y2-y1
+	if dx == 0 && dy == 0 {
+		return 0, math.Hypot(x-x1, y-y1)
+	}
+	t := ((x-x1)*dx + (y-y1)*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return t, math.Hypot(x-(x1+t*dx), y-(y1+t*dy))
+}
+
+// encodePolyline encodes points in Google's polyline format with precision of 5 decimal places.
+func encodePolyline(points ShapePoints) string {
+	var builder strings.Builder
+	var lastLat, lastLon int64
+	for _, point := range points {
+		lat := int64(math.Round(float64(point.Latitude) * 1e5))
+		lon := int64(math.Round(float64(point.Longitude) * 1e5))
+		encodePolylineValue(&builder, lat-lastLat)
+		encodePolylineValue(&builder, lon-lastLon)
+		lastLat, lastLon = lat, lon
+	}
+	return builder.String()
+}
+
+func encodePolylineValue(builder *strings.Builder, value int64) {
+	shifted := value << 1
+	if value < 0 {
+		shifted = ^shifted
+	}
+	for shifted >= 0x20 {
+		builder.WriteByte(byte(0x20|shifted&0x1f) + 63)
+		shifted >>= 5
+	}
+	builder.WriteByte(byte(shifted) + 63)
+}
@@ -0,0 +1,65 @@
+package GTFS
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodePolyline(t *testing.T) {
+	points := ShapePoints{
+		{Latitude: 38.5, Longitude: -120.2},
+		{Latitude: 40.7, Longitude: -120.95},
+		{Latitude: 43.252, Longitude: -126.453},
+	}
+
+	// example from Google's documentation
+	expected := "_p~iF~ps|U_ulLnnqC_mqNvxq`@"
+	if result := encodePolyline(points); result != expected {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, expected)
+	}
+}
+
+func TestSimplifyShape(t *testing.T) {
+	// going north, with a 5 m bend at the third point
+	points := ShapePoints{
+		{ShapeSequence: 0, Latitude: 51.1000, Longitude: 17.0000},
+		{ShapeSequence: 1, Latitude: 51.1010, Longitude: 17.0000},
+		{ShapeSequence: 2, Latitude: 51.1020, Longitude: 17.00007},
+		{ShapeSequence: 3, Latitude: 51.1030, Longitude: 17.0000},
+		{ShapeSequence: 4, Latitude: 51.1040, Longitude: 17.0000},
+	}
+	stop := StopOnMap{StopOnDemand: StopOnDemand{Stop: Stop{Latitude: 51.1030, Longitude: 17.0001}}}
+
+	tests := []struct {
+		stops     []StopOnMap
+		tolerance float64
+		expected  []int
+	}{
+		{nil, 1, []int{0, 1, 2, 3, 4}},
+		{nil, 3, []int{0, 2, 4}},
+		{nil, 10, []int{0, 4}},
+		{[]StopOnMap{stop}, 10, []int{0, 3, 4}},
+	}
+
+	for _, test := range tests {
+		var result []int
+		for _, point := range simplifyShape(points, test.stops, test.tolerance) {
+			result = append(result, point.ShapeSequence)
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, test.expected)
+		}
+	}
+}
+
+func TestMapDataApply(t *testing.T) {
+	data := MapData{Shapes: []Shape{{ShapeID: 1, Points: ShapePoints{{Latitude: 38.5, Longitude: -120.2}}}}}
+
+	result := data.Apply(MapOptions{Polyline: true})
+	if result.Shapes[0].Points != nil || result.Shapes[0].Polyline != "_p~iF~ps|U" {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result.Shapes[0], "_p~iF~ps|U")
+	}
+	if len(data.Shapes[0].Points) != 1 {
+		t.Errorf("Original data was modified: %v", data.Shapes[0])
+	}
+}
@@ -0,0 +1,92 @@
+package GTFS
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrStationNotFound is returned when looking up a station by unknown ID.
+var ErrStationNotFound = errors.New("station not found")
+
+// platforms with the same name further apart than that are put into separate stations
+const stationRadius = 500.0
+
+// Station groups platforms (stops) which share a name and lie close to each other,
+// like GTFS parent_station. Its ID is the lowest ID of its platforms.
+type Station struct {
+	ID          int
+	Name        string
+	Latitude    float64
+	Longitude   float64
+	BoundingBox BoundingBox
+	Platforms   []Stop
+}
+
+func newStation(platforms []Stop) Station {
+	sort.Slice(platforms, func(i, j int) bool {
+		return platforms[i].ID < platforms[j].ID
+	})
+
+	station := Station{ID: platforms[0].ID, Name: platforms[0].Name, Platforms: platforms}
+	for _, stop := range platforms {
+		station.Latitude += stop.Latitude / float64(len(platforms))
+		station.Longitude += stop.Longitude / float64(len(platforms))
+		station.BoundingBox = station.BoundingBox.extend(stop.Latitude, stop.Longitude)
+	}
+	return station
+}
+
+// clusterStops splits stops into groups where each stop is within stationRadius from some other stop of the group.
+func clusterStops(stops []Stop) [][]Stop {
+	var clusters [][]Stop
+	assigned := make([]bool, len(stops))
+	for i := range stops {
+		if assigned[i] {
+			continue
+		}
+		assigned[i] = true
+		cluster := []Stop{stops[i]}
+		for k := 0; k < len(cluster); k++ {
+			for j := range stops {
+				if !assigned[j] && distance(cluster[k].Latitude, cluster[k].Longitude, stops[j].Latitude, stops[j].Longitude) <= stationRadius {
+					assigned[j] = true
+					cluster = append(cluster, stops[j])
+				}
+			}
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
+
+// newStations groups stops into stations, ordered by name and ID.
+func newStations(stops []Stop) []Station {
+	byName := map[string][]Stop{}
+	for _, stop := range stops {
+		byName[stop.Name] = append(byName[stop.Name], stop)
+	}
+
+	var stations []Station
+	for _, group := range byName {
+		for _, cluster := range clusterStops(group) {
+			stations = append(stations, newStation(cluster))
+		}
+	}
+
+	sort.Slice(stations, func(i, j int) bool {
+		if stations[i].Name != stations[j].Name {
+			return stations[i].Name < stations[j].Name
+		}
+		return stations[i].ID < stations[j].ID
+	})
+	return stations
+}
+
+func findStation(stations []Station, stationID int) (Station, error) {
+	for _, station := range stations {
+		if station.ID == stationID {
+			return station, nil
+		}
+	}
+	return Station{}, ErrStationNotFound
+}
@@ -0,0 +1,57 @@
+package GTFS
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewStations(t *testing.T) {
+	stops := []Stop{
+		{"Plac Grunwaldzki", 12, 51.1120, 17.0600, 0},
+		{"Plac Grunwaldzki", 10, 51.1110, 17.0610, 0},
+		// same name, other end of the city
+		{"Plac Grunwaldzki", 30, 51.1500, 16.9000, 0},
+		{"Pilczyce", 20, 51.1300, 17.0000, 0},
+	}
+
+	stations := newStations(stops)
+
+	type result struct {
+		ID        int
+		Name      string
+		Platforms []int
+	}
+	var results []result
+	for _, station := range stations {
+		var platforms []int
+		for _, platform := range station.Platforms {
+			platforms = append(platforms, platform.ID)
+		}
+		results = append(results, result{station.ID, station.Name, platforms})
+	}
+
+	expected := []result{
+		{20, "Pilczyce", []int{20}},
+		{10, "Plac Grunwaldzki", []int{10, 12}},
+		{30, "Plac Grunwaldzki", []int{30}},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, results, expected)
+	}
+
+	station := stations[1]
+	if station.BoundingBox != (BoundingBox{51.1110, 17.0600, 51.1120, 17.0610}) {
+		t.Errorf("Wrong bounding box: %v", station.BoundingBox)
+	}
+	if !station.BoundingBox.Contains(station.Latitude, station.Longitude) {
+		t.Errorf("Centroid outside of bounding box: %f, %f", station.Latitude, station.Longitude)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	// Plac Grunwaldzki - Rynek in Wrocław, roughly 2 km
+	d := distance(51.1115, 17.0606, 51.1100, 17.0320)
+	if d < 1900 || d > 2100 {
+		t.Errorf("Wrong distance: %f", d)
+	}
+}
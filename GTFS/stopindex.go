@@ -0,0 +1,101 @@
+package GTFS
+
+import (
+	"math"
+	"sort"
+)
+
+// size of spatial grid cell in degrees, roughly 550 m in both directions at Wrocław's latitude
+const (
+	gridLatitudeStep  = 0.005
+	gridLongitudeStep = 0.008
+)
+
+type gridCell struct {
+	lat int
+	lon int
+}
+
+func cellOf(lat, lon float64) gridCell {
+	return gridCell{int(math.Floor(lat / gridLatitudeStep)), int(math.Floor(lon / gridLongitudeStep))}
+}
+
+// stopIndex keeps data derived from the list of stops: stations, spatial grid, search entries and routes serving each stop.
+// it's built once per feed.
+type stopIndex struct {
+	stops    []Stop
+	stations []Station
+	// key -> grid cell, value -> indexes in stops
+	grid map[gridCell][]int
+	// key -> stop ID, value -> sorted route IDs
+	routes map[int][]string
+	// one per station
+	searchEntries []searchEntry
+}
+
+func newStopIndex(stops []Stop, routes map[int][]string) *stopIndex {
+	index := &stopIndex{
+		stops:    stops,
+		stations: newStations(stops),
+		grid:     map[gridCell][]int{},
+		routes:   routes,
+	}
+	for idx, stop := range stops {
+		cell := cellOf(stop.Latitude, stop.Longitude)
+		index.grid[cell] = append(index.grid[cell], idx)
+	}
+	for _, routeIDs := range routes {
+		sort.Strings(routeIDs)
+	}
+	index.searchEntries = newSearchEntries(index.stations, routes)
+	return index
+}
+
+type NearbyStop struct {
+	Stop
+	// in metres
+	Distance float64
+	Routes   []string
+}
+
+// near returns stops within radius (in metres) from given point, ordered by distance.
+// at most limit stops are returned, zero means no limit.
+func (index *stopIndex) near(lat, lon, radius float64, limit int) []NearbyStop {
+	// degrees spanned by radius; longitude degrees shrink towards poles
+	latDelta := radius / earthRadius * 180 / math.Pi
+	lonDelta := latDelta / math.Max(math.Cos(lat*math.Pi/180), 0.01)
+	min := cellOf(lat-latDelta, lon-lonDelta)
+	max := cellOf(lat+latDelta, lon+lonDelta)
+
+	result := []NearbyStop{}
+	for cellLat := min.lat; cellLat <= max.lat; cellLat++ {
+		for cellLon := min.lon; cellLon <= max.lon; cellLon++ {
+			for _, idx := range index.grid[gridCell{cellLat, cellLon}] {
+				stop := index.stops[idx]
+				d := distance(lat, lon, stop.Latitude, stop.Longitude)
+				if d <= radius {
+					result = append(result, NearbyStop{stop, d, index.routesAt(stop.ID)})
+				}
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Distance != result[j].Distance {
+			return result[i].Distance < result[j].Distance
+		}
+		return result[i].ID < result[j].ID
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func (index *stopIndex) routesAt(stopID int) []string {
+	routes := index.routes[stopID]
+	if routes == nil {
+		return []string{}
+	}
+	return routes
+}
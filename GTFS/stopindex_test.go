@@ -0,0 +1,42 @@
+package GTFS
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStopIndexNear(t *testing.T) {
+	stops := []Stop{
+		{"Plac Grunwaldzki", 1, 51.1115, 17.0606, 0},
+		{"Plac Grunwaldzki", 2, 51.1118, 17.0612, 0},
+		{"Rynek", 3, 51.1100, 17.0320, 0},
+		{"Pilczyce", 4, 51.1300, 16.9700, 0},
+	}
+	index := newStopIndex(stops, map[int][]string{1: {"33", "D", "145"}})
+
+	tests := []struct {
+		radius   float64
+		limit    int
+		expected []int
+	}{
+		{100, 0, []int{1, 2}},
+		{3000, 0, []int{1, 2, 3}},
+		{3000, 2, []int{1, 2}},
+		{10000, 0, []int{1, 2, 3, 4}},
+	}
+
+	for _, test := range tests {
+		var result []int
+		for _, stop := range index.near(51.1115, 17.0606, test.radius, test.limit) {
+			result = append(result, stop.ID)
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf(`Wrong result for radius %.0f. Got "%v", expected: "%v"`, test.radius, result, test.expected)
+		}
+	}
+
+	nearest := index.near(51.1115, 17.0606, 100, 1)[0]
+	if nearest.Distance != 0 || !reflect.DeepEqual(nearest.Routes, []string{"145", "33", "D"}) {
+		t.Errorf("Wrong nearest stop: %v", nearest)
+	}
+}
@@ -0,0 +1,557 @@
+package GTFS
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Store gives read access to transit data. HTTP handlers depend only on this interface,
+// so that storage engine can be swapped without touching router code.
+type Store interface {
+	GetAllStops() ([]Stop, error)
+	GetStopByID(stopID int) (Stop, error)
+	GetStopByCode(code int) (Stop, error)
+	GetStations() ([]Station, error)
+	GetStation(stationID int) (Station, error)
+	GetStopsNear(lat, lon, radius float64, limit int) ([]NearbyStop, error)
+	SearchStops(query string, limit int) ([]StopSearchResult, error)
+	GetTransfers(stopID int) ([]Transfer, error)
+	GetAllRouteIDs() ([]Route, error)
+	GetRouteVariants(routeID string) ([]RouteVariant, error)
+	GetRouteVariantsByStopName(stopName string) ([]RouteVariant, error)
+	GetRouteVariantsByStopID(stopID int) ([]RouteVariant, error)
+	GetTimetable(routeID, stopName, direction string) (TimeTable, error)
+	GetTimetableForDate(routeID, stopName, direction string, date time.Time) (DateTimeTable, error)
+	GetTimetableAtStop(routeID string, stopID int, direction string) (TimeTable, error)
+	GetTimetableAtStopForDate(routeID string, stopID int, direction string, date time.Time) (DateTimeTable, error)
+	GetRouteInfo(routeID string) (RouteInfo, error)
+	GetRouteDirections(routeID string) (RouteDirections, error)
+	GetRouteDirectionsThroughStop(routeID, stopName string) (RouteDirections, error)
+	// times of trip's run on given service date, today's or, with realtime, the current run's if date is zero
+	GetTripTimeline(tripID int, date time.Time) (TripTimeline, error)
+	GetStopsForRouteID(routeID string) (StopsForRoute, error)
+	GetMapData(routeID, direction, stopName string) (MapData, error)
+	GetMapDataForTripID(tripID int) (MapData, error)
+	GetUpcomingDepartures(stopNames []string, query DepartureQuery) ([]UpcomingDepartures, error)
+	GetDepartureBoard(stopNames []string, query DepartureQuery) (DepartureBoard, error)
+	PlanJourneys(request JourneyRequest) ([]Journey, error)
+	GetIsochrone(request IsochroneRequest) (Isochrone, error)
+	GetScheduledVehicles(query VehicleQuery) ([]ScheduledVehicle, error)
+	GetTrip(tripID int) (Trip, error)
+	GetFeedVersion() (string, error)
+	GetNetwork() (Network, error)
+	GetAgencyIDs() ([]string, error)
+}
+
+// ErrStopNotFound is returned when looking up a stop by unknown ID or code.
+var ErrStopNotFound = errors.New("stop not found")
+
+// ErrTripNotFound is returned when there's no trip with given ID.
+var ErrTripNotFound = errors.New("trip not found")
+
+type Stop struct {
+	Name      string
+	ID        int
+	Latitude  float64
+	Longitude float64
+	Code      int
+}
+
+type Route struct {
+	ID    string
+	IsBus bool
+}
+
+type RouteVariant struct {
+	RouteID   string
+	IsBus     bool
+	FirstStop string
+	LastStop  string
+	TripIDs   []int
+}
+
+type TimeTableEntry struct {
+	TripID        int
+	ArrivalTime   ServiceTime
+	DepartureTime ServiceTime
+}
+
+type TimeTable struct {
+	RouteID   string
+	StopName  string
+	Direction string
+	Weekdays  []TimeTableEntry
+	Saturdays []TimeTableEntry
+	Sundays   []TimeTableEntry
+}
+
+// timeTableRow is a timetable entry together with service it belongs to and platform it happens at
+type timeTableRow struct {
+	Entry     TimeTableEntry
+	ServiceID int
+	StopID    int
+}
+
+// rowsAtStop keeps rows of the platform with given ID.
+func rowsAtStop(rows []timeTableRow, stopID int) []timeTableRow {
+	var result []timeTableRow
+	for _, row := range rows {
+		if row.StopID == stopID {
+			result = append(result, row)
+		}
+	}
+	return result
+}
+
+func newTimeTable(routeID, stopName, direction string, rows []timeTableRow, calendar *ServiceCalendar) TimeTable {
+	timeTable := TimeTable{
+		RouteID:   routeID,
+		StopName:  stopName,
+		Direction: direction,
+		Weekdays:  []TimeTableEntry{},
+		Saturdays: []TimeTableEntry{},
+		Sundays:   []TimeTableEntry{},
+	}
+
+	for _, row := range rows {
+		timeTable.add(row.Entry, calendar.DayTypes(row.ServiceID))
+	}
+
+	timeTable.sort()
+	return timeTable
+}
+
+// add puts entry into timetables of given day types
+func (tt *TimeTable) add(entry TimeTableEntry, dayTypes []DayType) {
+	for _, dayType := range dayTypes {
+		switch dayType {
+		case Weekday:
+			tt.Weekdays = append(tt.Weekdays, entry)
+		case Saturday:
+			tt.Saturdays = append(tt.Saturdays, entry)
+		case Sunday:
+			tt.Sundays = append(tt.Sundays, entry)
+		}
+	}
+}
+
+func (tt TimeTable) sort() {
+	type Predicate func(i, j int) bool
+	predFactory := func(slice []TimeTableEntry) Predicate {
+		return func(i, j int) bool {
+			return slice[i].ArrivalTime.Seconds < slice[j].ArrivalTime.Seconds
+		}
+	}
+	sort.Slice(tt.Weekdays, predFactory(tt.Weekdays))
+	sort.Slice(tt.Saturdays, predFactory(tt.Saturdays))
+	sort.Slice(tt.Sundays, predFactory(tt.Sundays))
+}
+
+// DateTimeTable lists departures on one particular date, taking holidays and service exceptions into account.
+type DateTimeTable struct {
+	RouteID   string
+	StopName  string
+	Direction string
+	Date      string
+	DayType   DayType
+	Entries   []TimeTableEntry
+}
+
+func newDateTimeTable(routeID, stopName, direction string, date time.Time, rows []timeTableRow, calendar *ServiceCalendar) DateTimeTable {
+	timeTable := DateTimeTable{
+		RouteID:   routeID,
+		StopName:  stopName,
+		Direction: direction,
+		Date:      date.Format("2006-01-02"),
+		DayType:   calendar.DayType(date),
+		Entries:   []TimeTableEntry{},
+	}
+
+	for _, row := range rows {
+		if calendar.IsActive(row.ServiceID, date) {
+			entry := row.Entry
+			entry.ArrivalTime = entry.ArrivalTime.On(date)
+			entry.DepartureTime = entry.DepartureTime.On(date)
+			timeTable.Entries = append(timeTable.Entries, entry)
+		}
+	}
+
+	sort.Slice(timeTable.Entries, func(i, j int) bool {
+		return timeTable.Entries[i].ArrivalTime.Seconds < timeTable.Entries[j].ArrivalTime.Seconds
+	})
+	return timeTable
+}
+
+type DayType int
+
+const (
+	Weekday  DayType = 0
+	Saturday DayType = 1
+	Sunday   DayType = 2
+)
+
+type RouteInfo struct {
+	RouteID     string
+	RouteType   string
+	IsBus       bool
+	ValidFrom   string
+	ValidUntil  string
+	AgencyName  string
+	AgencyUrl   string
+	AgencyPhone string
+	// "#RRGGBB"
+	Color string
+}
+
+type RouteDirections struct {
+	RouteID    string
+	Directions []string
+}
+
+type TripTimelineEntry struct {
+	StopName      string
+	DepartureTime ServiceTime
+	OnDemand      bool
+	StopID        int `json:"-"`
+	StopSequence  int `json:"-"`
+	// set only when realtime data is used
+	Prediction *Prediction `json:",omitempty"`
+	Stale      bool        `json:",omitempty"`
+}
+
+type Trip struct {
+	TripID   int
+	RouteID  string
+	Headsign string
+	IsBus    bool
+}
+
+type TripTimeline struct {
+	TripID   int
+	Timeline []TripTimelineEntry
+}
+
+type StopsForRoute struct {
+	RouteID   string
+	StopNames []string
+}
+
+// key -> shapeID
+// value -> list of trip IDs
+type ShapeMap map[int][]int
+
+type ShapePoint struct {
+	ShapeID       int `json:"-"`
+	ShapeSequence int `json:"-"`
+	Latitude      float32
+	Longitude     float32
+}
+
+type ShapePoints []ShapePoint
+
+type StopOnDemand struct {
+	Stop
+	OnDemand bool
+}
+
+type Shape struct {
+	ShapeID int
+	Points  ShapePoints `json:",omitempty"`
+	// Google encoded polyline, replaces Points if requested
+	Polyline string `json:",omitempty"`
+}
+
+type StopOnMap struct {
+	StopOnDemand
+	FirstOrLast bool
+}
+
+type MapData struct {
+	RouteID string `json:"-"`
+	Shapes  []Shape
+	Stops   []StopOnMap
+}
+
+// mapStops merges stops of given trips (each in stop sequence order) into a set of stops to put on the map.
+func mapStops(trips [][]StopOnDemand) []StopOnMap {
+	// value (bool) marks first or last stop in the trip
+	stopsMap := map[StopOnDemand]bool{}
+	for _, newStops := range trips {
+		for idx, newStop := range newStops {
+			if _, ok := stopsMap[newStop]; ok {
+				// we already have such a stop. do nothing.
+			} else {
+				stopsMap[newStop] = false
+			}
+
+			// mark either first or last stop in the trip
+			if idx == 0 || idx == len(newStops)-1 {
+				stopsMap[newStop] = true
+			}
+		}
+	}
+
+	stops := make([]StopOnMap, 0, len(stopsMap))
+	for stop, firstOrLast := range stopsMap {
+		stops = append(stops, StopOnMap{stop, firstOrLast})
+	}
+	return stops
+}
+
+type UpcomingDeparture struct {
+	Stop          Stop `json:"-"`
+	TripID        int
+	DepartureTime ServiceTime
+	OnDemand      bool
+	RouteID       string
+	IsBus         bool
+	Direction     string
+	ServiceID     int `json:"-"`
+	StopSequence  int `json:"-"`
+	// set only when realtime data is used
+	Prediction *Prediction `json:",omitempty"`
+	Stale      bool        `json:",omitempty"`
+}
+
+// timetables are given in local time of Wrocław
+var warsaw, _ = time.LoadLocation("Europe/Warsaw")
+
+// DepartureQuery tells which departures should be listed.
+type DepartureQuery struct {
+	// moment from which departures are listed
+	At time.Time
+	// how far ahead of At departures are listed. zero means all remaining departures of At's service day.
+	Window time.Duration
+	// maximum number of departures listed for each stop. zero means default of five.
+	Limit int
+	// when not empty, only departures from these platforms are listed
+	StopIDs []int
+	// when not empty, only departures of these routes are listed
+	RouteIDs []string
+	// when not empty, only departures towards these headsigns are listed
+	Headsigns []string
+	Vehicle   VehicleKind
+}
+
+type VehicleKind int
+
+const (
+	AnyVehicle VehicleKind = 0
+	Tram       VehicleKind = 1
+	Bus        VehicleKind = 2
+)
+
+const defaultDepartureLimit = 5
+
+func (query DepartureQuery) limit() int {
+	if query.Limit <= 0 {
+		return defaultDepartureLimit
+	}
+	return query.Limit
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// matches checks departure against query's stop, route, headsign and vehicle filters.
+func (query DepartureQuery) matches(departure UpcomingDeparture) bool {
+	if len(query.StopIDs) > 0 && !containsInt(query.StopIDs, departure.Stop.ID) {
+		return false
+	}
+	if len(query.RouteIDs) > 0 && !containsFold(query.RouteIDs, departure.RouteID) {
+		return false
+	}
+	if len(query.Headsigns) > 0 && !containsFold(query.Headsigns, departure.Direction) {
+		return false
+	}
+	switch query.Vehicle {
+	case Tram:
+		return !departure.IsBus
+	case Bus:
+		return departure.IsBus
+	}
+	return true
+}
+
+// serviceDates returns dates of services which can depart within the query's time span.
+// trips after midnight belong to previous day's service, so that day is included as well.
+func (query DepartureQuery) serviceDates() []time.Time {
+	date := serviceDate(query.At).AddDate(0, 0, -1)
+	last := serviceDate(query.At.Add(query.Window))
+
+	var dates []time.Time
+	for !date.After(last) {
+		dates = append(dates, date)
+		date = date.AddDate(0, 0, 1)
+	}
+	return dates
+}
+
+// acceptDeparture checks whether departure, already bound to a service date, passes query's filters,
+// runs and departs within the query's time span.
+func acceptDeparture(departure UpcomingDeparture, calendar *ServiceCalendar, query DepartureQuery) bool {
+	if !query.matches(departure) || !calendar.IsActive(departure.ServiceID, departure.DepartureTime.Date) {
+		return false
+	}
+
+	// timetables have minute precision, departure is still upcoming during its minute
+	departs := departure.DepartureTime.Time()
+	if departs.Before(query.At.Truncate(time.Minute)) {
+		return false
+	}
+	return query.Window == 0 || !departs.After(query.At.Add(query.Window))
+}
+
+// filterDepartures binds departures to service dates and keeps the ones matching the query, ordered by time.
+func filterDepartures(departures []UpcomingDeparture, calendar *ServiceCalendar, query DepartureQuery) []UpcomingDeparture {
+	var accepted []UpcomingDeparture
+	for _, date := range query.serviceDates() {
+		for _, departure := range departures {
+			departure.DepartureTime = departure.DepartureTime.On(date)
+			if acceptDeparture(departure, calendar, query) {
+				accepted = append(accepted, departure)
+			}
+		}
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].DepartureTime.Time().Before(accepted[j].DepartureTime.Time())
+	})
+
+	// used to filter out the same departures with differing tripIDs
+	// (e.g. separate services for weekdays and fridays running on the same day)
+	type key struct {
+		stopID    int
+		time      time.Time
+		onDemand  bool
+		routeID   string
+		direction string
+	}
+	seen := map[key]bool{}
+
+	result := accepted[:0]
+	for _, departure := range accepted {
+		k := key{departure.Stop.ID, departure.DepartureTime.Time(), departure.OnDemand, departure.RouteID, departure.Direction}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		result = append(result, departure)
+	}
+	return result
+}
+
+type UpcomingDepartures struct {
+	Stop       Stop
+	Departures []UpcomingDeparture
+}
+
+// uniqueStopNames drops repeated stop names, keeping the order of the first occurrences.
+func uniqueStopNames(stopNames []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, stopName := range stopNames {
+		if !seen[stopName] {
+			seen[stopName] = true
+			unique = append(unique, stopName)
+		}
+	}
+	return unique
+}
+
+// groupDepartures groups departures by stop and keeps first few departures for each one.
+// groups are ordered by stop name and ID.
+func groupDepartures(data []UpcomingDeparture, limit int) []UpcomingDepartures {
+	departures := map[int][]UpcomingDeparture{}
+	for _, departure := range data {
+		key := departure.Stop.ID
+		if val, ok := departures[key]; ok {
+			departures[key] = append(val, departure)
+		} else {
+			departures[key] = []UpcomingDeparture{departure}
+		}
+	}
+
+	result := make([]UpcomingDepartures, len(departures))
+	idx := 0
+	for key, list := range departures {
+		lastIdx := limit
+		if len(list) <= lastIdx {
+			lastIdx = len(list)
+		}
+		departures[key] = list[0:lastIdx]
+
+		result[idx] = UpcomingDepartures{departures[key][0].Stop, departures[key]}
+		idx++
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Stop.Name != result[j].Stop.Name {
+			return result[i].Stop.Name < result[j].Stop.Name
+		}
+		return result[i].Stop.ID < result[j].Stop.ID
+	})
+	return result
+}
+
+// BoardDeparture is a departure tagged with the platform it leaves from.
+type BoardDeparture struct {
+	UpcomingDeparture
+	StopID    int
+	StopName  string
+	Latitude  float64
+	Longitude float64
+}
+
+// DepartureBoard lists departures from all requested stops in chronological order.
+type DepartureBoard struct {
+	Departures []BoardDeparture
+}
+
+// newDepartureBoard merges departures of all stops, sorts them and keeps first few.
+// departures leaving at the same time are ordered by stop ID, route ID and trip ID.
+func newDepartureBoard(data []UpcomingDeparture, limit int) DepartureBoard {
+	sorted := make([]UpcomingDeparture, len(data))
+	copy(sorted, data)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if !a.DepartureTime.Time().Equal(b.DepartureTime.Time()) {
+			return a.DepartureTime.Time().Before(b.DepartureTime.Time())
+		}
+		if a.Stop.ID != b.Stop.ID {
+			return a.Stop.ID < b.Stop.ID
+		}
+		if a.RouteID != b.RouteID {
+			return a.RouteID < b.RouteID
+		}
+		return a.TripID < b.TripID
+	})
+
+	if len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+
+	board := DepartureBoard{Departures: make([]BoardDeparture, len(sorted))}
+	for idx, departure := range sorted {
+		stop := departure.Stop
+		board.Departures[idx] = BoardDeparture{departure, stop.ID, stop.Name, stop.Latitude, stop.Longitude}
+	}
+	return board
+}
@@ -0,0 +1,170 @@
+package GTFS
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// stops are left out of tiles showing more than a district
+	minStopsZoom = 13
+	maxTileZoom  = 22
+	// tiles kept in memory before cache is cleared
+	maxCachedTiles = 10000
+)
+
+var errNoSuchTile = errors.New("no such tile")
+
+// NetworkShape is a shape driven along by a route
+type NetworkShape struct {
+	ShapeID int
+	RouteID string
+	IsBus   bool
+	// "#RRGGBB"
+	Color  string
+	Points ShapePoints
+}
+
+type NetworkStop struct {
+	Stop
+	Routes []string
+}
+
+// Network is everything drawn on the network map
+type Network struct {
+	Shapes []NetworkShape
+	Stops  []NetworkStop
+}
+
+// newNetworkStops tags stops with routes serving them
+func newNetworkStops(stops []Stop, routes map[int][]string) []NetworkStop {
+	result := make([]NetworkStop, len(stops))
+	for i, stop := range stops {
+		routeIDs := append([]string{}, routes[stop.ID]...)
+		sort.Strings(routeIDs)
+		result[i] = NetworkStop{stop, routeIDs}
+	}
+	return result
+}
+
+type tileKey struct {
+	z int
+	x int
+	y int
+}
+
+// tileCache keeps network and rendered tiles for one feed version
+type tileCache struct {
+	mutex   sync.Mutex
+	version string
+	network *Network
+	tiles   map[tileKey][]byte
+}
+
+func newTileCache() *tileCache {
+	return &tileCache{tiles: map[tileKey][]byte{}}
+}
+
+// tile returns encoded tile z/x/y, rendering it if it's not cached for current feed version.
+func (cache *tileCache) tile(store Store, z, x, y int) ([]byte, error) {
+	if z < 0 || z > maxTileZoom || x < 0 || y < 0 || x >= 1<<uint(z) || y >= 1<<uint(z) {
+		return nil, errNoSuchTile
+	}
+
+	version, err := store.GetFeedVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if cache.network == nil || version != cache.version {
+		network, err := store.GetNetwork()
+		if err != nil {
+			return nil, err
+		}
+		cache.version, cache.network, cache.tiles = version, &network, map[tileKey][]byte{}
+	}
+
+	key := tileKey{z, x, y}
+	if tile, ok := cache.tiles[key]; ok {
+		return tile, nil
+	}
+	if len(cache.tiles) >= maxCachedTiles {
+		cache.tiles = map[tileKey][]byte{}
+	}
+	tile := renderTile(cache.network, z, x, y)
+	cache.tiles[key] = tile
+	return tile, nil
+}
+
+// renderTile draws shapes in "routes" layer and stops in "stops" layer.
+func renderTile(network *Network, z, x, y int) []byte {
+	routes := &mvtLayer{name: "routes"}
+	for _, shape := range network.Shapes {
+		if len(shape.Points) == 0 {
+			continue
+		}
+		tolerance := zoomTolerance(z, float64(shape.Points[0].Latitude))
+		lines := tileLines(simplifyShape(shape.Points, nil, tolerance), z, x, y)
+
+		vehicle := "tram"
+		if shape.IsBus {
+			vehicle = "bus"
+		}
+		routes.addLines(lines, map[string]string{
+			"route_id": shape.RouteID,
+			"vehicle":  vehicle,
+			"color":    shape.Color,
+		})
+	}
+
+	stops := &mvtLayer{name: "stops"}
+	if z >= minStopsZoom {
+		for _, stop := range network.Stops {
+			position := tileCoordinates(stop.Latitude, stop.Longitude, z, x, y)
+			if !inTile(position) {
+				continue
+			}
+			stops.addPoint(position, map[string]string{
+				"stop_id": fmt.Sprint(stop.ID),
+				"name":    stop.Name,
+				"routes":  strings.Join(stop.Routes, ","),
+			})
+		}
+	}
+
+	return encodeTile(routes, stops)
+}
+
+// tileLines returns parts of shape crossing the tile, clipped to its buffer and without repeated positions.
+func tileLines(points ShapePoints, z, x, y int) [][]tilePosition {
+	var lines [][]tilePosition
+	var line []tilePosition
+	finish := func() {
+		if len(line) >= 2 {
+			lines = append(lines, line)
+		}
+		line = nil
+	}
+
+	for i := 1; i < len(points); i++ {
+		a := tileCoordinates(float64(points[i-1].Latitude), float64(points[i-1].Longitude), z, x, y)
+		b := tileCoordinates(float64(points[i].Latitude), float64(points[i].Longitude), z, x, y)
+		a, b, ok := clipSegment(a, b)
+		if !ok || a == b {
+			continue
+		}
+		if len(line) == 0 || line[len(line)-1] != a {
+			finish()
+			line = []tilePosition{a}
+		}
+		line = append(line, b)
+	}
+	finish()
+	return lines
+}
@@ -0,0 +1,88 @@
+package GTFS
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestMVTGeometry(t *testing.T) {
+	// examples from the specification
+	layer := &mvtLayer{}
+	layer.addPoint(tilePosition{25, 17}, nil)
+	layer.addLines([][]tilePosition{{{2, 2}, {2, 10}, {10, 10}}}, nil)
+
+	expected := [][]uint32{{9, 50, 34}, {9, 4, 4, 18, 0, 16, 16, 0}}
+	for i, feature := range layer.features {
+		if !reflect.DeepEqual(feature.geometry, expected[i]) {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, feature.geometry, expected[i])
+		}
+	}
+}
+
+func TestTileCache(t *testing.T) {
+	store := newTestMemoryStore(t)
+	cache := newTileCache()
+
+	tests := []struct {
+		z        int
+		x        int
+		y        int
+		expected []string
+		missing  []string
+	}{
+		// Plac Grunwaldzki, where shape 100 starts
+		{14, 8968, 5477, []string{"routes", "route_id", "33", "tram", "#D2232A", "stops", "Plac Grunwaldzki"}, []string{"Pilczyce"}},
+		// no stops when zoomed out
+		{10, 560, 342, []string{"routes", "33"}, []string{"stops"}},
+		// somewhere else
+		{14, 0, 0, nil, []string{"routes", "stops"}},
+	}
+
+	for _, test := range tests {
+		tile, err := cache.tile(store, test.z, test.x, test.y)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, value := range test.expected {
+			if !bytes.Contains(tile, []byte(value)) {
+				t.Errorf(`Wrong result. Tile %d/%d/%d is missing "%v"`, test.z, test.x, test.y, value)
+			}
+		}
+		for _, value := range test.missing {
+			if bytes.Contains(tile, []byte(value)) {
+				t.Errorf(`Wrong result. Tile %d/%d/%d contains "%v"`, test.z, test.x, test.y, value)
+			}
+		}
+	}
+
+	if len(cache.tiles) != len(tests) {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, len(cache.tiles), len(tests))
+	}
+	if _, err := cache.tile(store, 2, 4, 0); err != errNoSuchTile {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, err, errNoSuchTile)
+	}
+}
+
+func TestClipSegment(t *testing.T) {
+	tests := []struct {
+		a        tilePosition
+		b        tilePosition
+		expected []tilePosition
+	}{
+		{tilePosition{10, 10}, tilePosition{20, 20}, []tilePosition{{10, 10}, {20, 20}}},
+		{tilePosition{100, 100}, tilePosition{100, -1000}, []tilePosition{{100, 100}, {100, -tileBuffer}}},
+		{tilePosition{-1000, 100}, tilePosition{10000, 100}, []tilePosition{{-tileBuffer, 100}, {tileExtent + tileBuffer, 100}}},
+		{tilePosition{-1000, -1000}, tilePosition{-1000, 10000}, nil},
+	}
+
+	for _, test := range tests {
+		var result []tilePosition
+		if a, b, ok := clipSegment(test.a, test.b); ok {
+			result = []tilePosition{a, b}
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, test.expected)
+		}
+	}
+}
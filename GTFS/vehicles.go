@@ -0,0 +1,249 @@
+package GTFS
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// VehicleQuery selects vehicles by time, area and route.
+type VehicleQuery struct {
+	// scheduled vehicles only
+	At time.Time
+	// nil means everywhere
+	Area *BoundingBox
+	// empty means all routes
+	RouteIDs []string
+}
+
+func (query VehicleQuery) matches(routeID string, lat, lon float64) bool {
+	if len(query.RouteIDs) > 0 && !containsFold(query.RouteIDs, routeID) {
+		return false
+	}
+	return query.Area == nil || query.Area.Contains(lat, lon)
+}
+
+// ScheduledVehicle is where a vehicle should be according to the timetable.
+type ScheduledVehicle struct {
+	TripID    int
+	RouteID   string
+	Headsign  string
+	IsBus     bool
+	Latitude  float64
+	Longitude float64
+	// degrees clockwise from north
+	Bearing float64
+	// both are the same while vehicle waits at a stop
+	PreviousStopID int
+	NextStopID     int
+}
+
+// pathPoint is a point of trip's path with its distance from path's start, in metres
+type pathPoint struct {
+	latitude  float64
+	longitude float64
+	distance  float64
+}
+
+// tripPath is the way trip goes with its stops placed on it
+type tripPath struct {
+	points []pathPoint
+	// by position in trip's stop times
+	stops      []float64
+	arrivals   []int
+	departures []int
+}
+
+// vehicleSchedule finds trips in progress and places them on their shapes.
+type vehicleSchedule struct {
+	planner *JourneyPlanner
+	// by planner's trip index
+	paths []tripPath
+}
+
+func newVehicleSchedule(planner *JourneyPlanner, shapes map[int]ShapePoints) *vehicleSchedule {
+	schedule := &vehicleSchedule{planner: planner, paths: make([]tripPath, len(planner.trips))}
+	for idx, trip := range planner.trips {
+		var stops []Stop
+		var path tripPath
+		for _, st := range trip.StopTimes {
+			stopIdx, ok := planner.stopIndexes[st.StopID]
+			if !ok {
+				continue
+			}
+			stops = append(stops, planner.stops[stopIdx])
+			path.arrivals = append(path.arrivals, newServiceTime(st.ArrivalTime).Seconds)
+			path.departures = append(path.departures, newServiceTime(st.DepartureTime).Seconds)
+		}
+		path.points, path.stops = placeStops(shapes[trip.ShapeID], stops)
+		schedule.paths[idx] = path
+	}
+	return schedule
+}
+
+// placeStops returns path along the shape, or straight between stops if there's no shape,
+// and distance along the path at which each stop lies.
+func placeStops(shape ShapePoints, stops []Stop) ([]pathPoint, []float64) {
+	if len(stops) == 0 {
+		return nil, nil
+	}
+
+	refLatitude := stops[0].Latitude
+	var points []pathPoint
+	if len(shape) < 2 {
+		for _, stop := range stops {
+			points = append(points, pathPoint{stop.Latitude, stop.Longitude, 0})
+		}
+	} else {
+		for _, point := range shape {
+			points = append(points, pathPoint{float64(point.Latitude), float64(point.Longitude), 0})
+		}
+	}
+
+	xs, ys := make([]float64, len(points)), make([]float64, len(points))
+	for i, point := range points {
+		xs[i], ys[i] = planar(point.latitude, point.longitude, refLatitude)
+		if i > 0 {
+			points[i].distance = points[i-1].distance + math.Hypot(xs[i]-xs[i-1], ys[i]-ys[i-1])
+		}
+	}
+	if len(points) == 1 {
+		return points, make([]float64, len(stops))
+	}
+
+	// stops are looked for further along the path than the previous one
+	distances := make([]float64, len(stops))
+	segment, offset := 0, 0.0
+	for i, stop := range stops {
+		x, y := planar(stop.Latitude, stop.Longitude, refLatitude)
+		bestSegment, bestOffset, bestDistance := segment, offset, math.Inf(1)
+		for j := segment; j+1 < len(points); j++ {
+			t, d := segmentProjection(x, y, xs[j], ys[j], xs[j+1], ys[j+1])
+			if j == segment {
+				t = math.Max(t, offset)
+			}
+			if d < bestDistance {
+				bestSegment, bestOffset, bestDistance = j, t, d
+			}
+		}
+		segment, offset = bestSegment, bestOffset
+		distances[i] = points[segment].distance + offset*(points[segment+1].distance-points[segment].distance)
+	}
+	return points, distances
+}
+
+// at returns position and bearing at given distance along the path
+func (path tripPath) at(distance float64) (float64, float64, float64) {
+	points := path.points
+	if len(points) == 1 {
+		return points[0].latitude, points[0].longitude, 0
+	}
+
+	// first segment ending after distance
+	i := sort.Search(len(points)-1, func(i int) bool {
+		return points[i+1].distance >= distance
+	})
+	if i == len(points)-1 {
+		i--
+	}
+	a, b := points[i], points[i+1]
+	t := 0.0
+	if b.distance > a.distance {
+		t = math.Max(0, math.Min(1, (distance-a.distance)/(b.distance-a.distance)))
+	}
+	return a.latitude + t*(b.latitude-a.latitude), a.longitude + t*(b.longitude-a.longitude),
+		bearing(a.latitude, a.longitude, b.latitude, b.longitude)
+}
+
+// position returns where the vehicle should be given seconds after start of its service day, ok is false
+// if it's not running then.
+func (path tripPath) position(seconds int) (lat, lon, heading float64, previous, next int, ok bool) {
+	count := len(path.stops)
+	if count < 2 || seconds < path.departures[0] || seconds > path.arrivals[count-1] {
+		return 0, 0, 0, 0, 0, false
+	}
+
+	for i := 0; i+1 < count; i++ {
+		if seconds < path.arrivals[i] {
+			break
+		}
+		if seconds < path.departures[i] {
+			// waiting at the stop, facing the way ahead
+			lat, lon, _ = path.at(path.stops[i])
+			_, _, heading = path.at(path.stops[i] + 1)
+			return lat, lon, heading, i, i, true
+		}
+		if seconds < path.arrivals[i+1] {
+			fraction := float64(seconds-path.departures[i]) / float64(path.arrivals[i+1]-path.departures[i])
+			lat, lon, heading = path.at(path.stops[i] + fraction*(path.stops[i+1]-path.stops[i]))
+			return lat, lon, heading, i, i + 1, true
+		}
+	}
+
+	lat, lon, heading = path.at(path.stops[count-1])
+	return lat, lon, heading, count - 1, count - 1, true
+}
+
+// vehicles returns trips in progress at query's time within its area, ordered by route and trip ID.
+func (schedule *vehicleSchedule) vehicles(query VehicleQuery) []ScheduledVehicle {
+	planner := schedule.planner
+	vehicles := []ScheduledVehicle{}
+
+	// trips started the day before can still be running after midnight
+	today := serviceDate(query.At)
+	for _, date := range []time.Time{today.AddDate(0, 0, -1), today} {
+		seconds := int(query.At.Unix() - ServiceTime{0, date}.Time().Unix())
+		active := map[int]bool{}
+
+		for idx, trip := range planner.trips {
+			lat, lon, heading, previous, next, ok := schedule.paths[idx].position(seconds)
+			if !ok {
+				continue
+			}
+			if !query.matches(trip.RouteID, lat, lon) {
+				continue
+			}
+
+			isActive, known := active[trip.ServiceID]
+			if !known {
+				isActive = planner.calendar.IsActive(trip.ServiceID, date)
+				active[trip.ServiceID] = isActive
+			}
+			if !isActive {
+				continue
+			}
+
+			stopIDs := tripStopIDs(planner, trip)
+			vehicles = append(vehicles, ScheduledVehicle{
+				TripID:         trip.TripID,
+				RouteID:        trip.RouteID,
+				Headsign:       trip.Headsign,
+				IsBus:          trip.IsBus,
+				Latitude:       lat,
+				Longitude:      lon,
+				Bearing:        math.Round(heading),
+				PreviousStopID: stopIDs[previous],
+				NextStopID:     stopIDs[next],
+			})
+		}
+	}
+
+	sort.Slice(vehicles, func(i, j int) bool {
+		if vehicles[i].RouteID != vehicles[j].RouteID {
+			return vehicles[i].RouteID < vehicles[j].RouteID
+		}
+		return vehicles[i].TripID < vehicles[j].TripID
+	})
+	return vehicles
+}
+
+// tripStopIDs returns IDs of trip's stops known to the planner, matching positions in trip's path
+func tripStopIDs(planner *JourneyPlanner, trip plannerTrip) []int {
+	var stopIDs []int
+	for _, st := range trip.StopTimes {
+		if _, ok := planner.stopIndexes[st.StopID]; ok {
+			stopIDs = append(stopIDs, st.StopID)
+		}
+	}
+	return stopIDs
+}
@@ -0,0 +1,65 @@
+package GTFS
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScheduledVehicles(t *testing.T) {
+	schedule := newVehicleSchedule(newTestPlanner(), nil)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2018, 10, 25, hour, minute, 0, 0, warsaw)
+	}
+
+	tests := []struct {
+		query    VehicleQuery
+		expected []string
+	}{
+		{VehicleQuery{At: at(10, 5)}, []string{"1 51.1050 0 1-2", "3 51.1000 0 1-2"}},
+		{VehicleQuery{At: at(10, 20)}, []string{"2 51.1179 0 3-4", "3 51.1100 0 2-4"}},
+		{VehicleQuery{At: at(10, 20), Area: &BoundingBox{51.115, 16.9, 51.13, 17.1}}, []string{"2 51.1179 0 3-4"}},
+		{VehicleQuery{At: at(12, 0)}, nil},
+		// service doesn't run then
+		{VehicleQuery{At: time.Date(2019, 1, 10, 10, 5, 0, 0, warsaw)}, nil},
+	}
+
+	for _, test := range tests {
+		var result []string
+		for _, vehicle := range schedule.vehicles(test.query) {
+			result = append(result, fmt.Sprintf("%s %.4f %.0f %d-%d", vehicle.RouteID, vehicle.Latitude, vehicle.Bearing,
+				vehicle.PreviousStopID, vehicle.NextStopID))
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, test.expected)
+		}
+	}
+}
+
+func TestPlaceStops(t *testing.T) {
+	// going east, then north
+	shape := ShapePoints{
+		{Latitude: 51.1000, Longitude: 17.0000},
+		{Latitude: 51.1000, Longitude: 17.0100},
+		{Latitude: 51.1100, Longitude: 17.0100},
+	}
+	stops := []Stop{
+		{Latitude: 51.1001, Longitude: 17.0000},
+		{Latitude: 51.1000, Longitude: 17.0101},
+		{Latitude: 51.1100, Longitude: 17.0101},
+	}
+
+	points, distances := placeStops(shape, stops)
+	path := tripPath{points: points, stops: distances}
+
+	var result []string
+	for _, d := range distances {
+		lat, lon, heading := path.at(d + 1)
+		result = append(result, fmt.Sprintf("%.4f,%.4f %.0f", lat, lon, heading))
+	}
+	expected := []string{"51.1000,17.0000 90", "51.1000,17.0100 0", "51.1100,17.0100 0"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, expected)
+	}
+}
@@ -0,0 +1,15 @@
+package News
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// News without periods are considered active for some time after they're published
+const activeNewsAge = 14 * 24 * time.Hour
+
+// ActiveNews returns news about changes in effect at given time, most recent first
+func ActiveNews(db *sqlx.DB, now time.Time) ([]NewsItem, error) {
+	return getNewsActiveAt(db, now, now.Add(-activeNewsAge))
+}
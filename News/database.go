@@ -1,11 +1,13 @@
 package News
 
 import (
+	"fmt"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
 	"log"
 	"os/user"
 	"path"
+	"time"
 )
 
 const schema = `
@@ -18,8 +20,58 @@ CREATE TABLE IF NOT EXISTS news (
 	affects_days TEXT,
 	body TEXT
 );
+
+CREATE TABLE IF NOT EXISTS news_routes (
+	url TEXT REFERENCES news(url),
+	route_id TEXT,
+	PRIMARY KEY (url, route_id)
+);
+
+CREATE TABLE IF NOT EXISTS news_periods (
+	url TEXT REFERENCES news(url),
+	start_time DATETIME,
+	end_time DATETIME
+);
 `
 
+// addNewsColumn adds a column to news table created by an older version
+func addNewsColumn(db *sqlx.DB, column, definition string) {
+	var count int
+	db.Get(&count, `SELECT COUNT(*) FROM pragma_table_info('news') WHERE name = $1`, column)
+	if count == 0 {
+		db.MustExec(`ALTER TABLE news ADD COLUMN ` + column + ` ` + definition)
+	}
+}
+
+// Database version kept in user_version pragma. Version 1 stores publication times in UTC.
+const databaseVersion = 1
+
+// migratePublishedOn converts publication times stored by older versions,
+// which kept Warsaw wall clock labelled as UTC, and marks the database as migrated
+func migratePublishedOn(db *sqlx.DB) {
+	var version int
+	db.Get(&version, `PRAGMA user_version`)
+	if version >= databaseVersion {
+		return
+	}
+
+	var news []NewsItem
+	if err := db.Select(&news, `SELECT url, published_on FROM news WHERE published_on IS NOT NULL`); err != nil {
+		log.Fatal(err)
+	}
+
+	tx := db.MustBegin()
+	for _, newsItem := range news {
+		t := newsItem.PublishedOn
+		publishedOn := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), warsaw)
+		tx.MustExec(`UPDATE news SET published_on = $1 WHERE url = $2`, publishedOn.UTC(), newsItem.Url)
+	}
+	tx.MustExec(fmt.Sprintf(`PRAGMA user_version = %d`, databaseVersion))
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func OpenDatabase() *sqlx.DB {
 	log.Println("Opening database...")
 
@@ -37,6 +89,8 @@ func OpenDatabase() *sqlx.DB {
 	}
 
 	db.MustExec(schema)
+	addNewsColumn(db, "affects_days_unparsed", "BOOLEAN NOT NULL DEFAULT 0")
+	migratePublishedOn(db)
 
 	return db
 }
@@ -44,6 +98,8 @@ func OpenDatabase() *sqlx.DB {
 func insertNewsIntoDB(db *sqlx.DB, news []NewsItem) {
 	tx := db.MustBegin()
 	for _, newsItem := range news {
+		// stored in UTC, so that times compare as text
+		newsItem.PublishedOn = newsItem.PublishedOn.UTC()
 		tx.NamedExec(`
 			INSERT OR IGNORE INTO news (url, title, published_on, synopsis, affects_lines, affects_days, body)
 			VALUES (:url, :title, :published_on, :synopsis, :affects_lines, :affects_days, :body)`,
@@ -57,6 +113,139 @@ func insertNewsIntoDB(db *sqlx.DB, news []NewsItem) {
 	log.Println("Commited to DB")
 }
 
+// linkNewsToRoutes replaces lines linked to crawled news with their RouteIDs
+func linkNewsToRoutes(db *sqlx.DB, news []NewsItem) {
+	tx := db.MustBegin()
+	for _, newsItem := range news {
+		if !newsItem.crawled {
+			continue
+		}
+		tx.MustExec(`DELETE FROM news_routes WHERE url = $1`, newsItem.Url)
+		for _, routeID := range newsItem.RouteIDs {
+			tx.MustExec(`INSERT INTO news_routes (url, route_id) VALUES ($1, $2)`, newsItem.Url, routeID)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Linking news to lines failed: %s", err)
+	}
+}
+
+// saveNewsPeriods replaces periods of crawled news with their Periods
+func saveNewsPeriods(db *sqlx.DB, news []NewsItem) {
+	tx := db.MustBegin()
+	for _, newsItem := range news {
+		if !newsItem.crawled {
+			continue
+		}
+		tx.MustExec(`UPDATE news SET affects_days_unparsed = $1 WHERE url = $2`, newsItem.AffectsDayUnparsed, newsItem.Url)
+		tx.MustExec(`DELETE FROM news_periods WHERE url = $1`, newsItem.Url)
+		for _, period := range newsItem.Periods {
+			tx.MustExec(`INSERT INTO news_periods (url, start_time, end_time) VALUES ($1, $2, $3)`,
+				newsItem.Url, utc(period.Start), utc(period.End))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Saving news periods failed: %s", err)
+	}
+}
+
+// utc converts time to UTC, so that stored times compare as text
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// loadNewsDetails fills route IDs and periods of news and moves their times to Warsaw
+func loadNewsDetails(db *sqlx.DB, news []NewsItem) error {
+	for i := range news {
+		news[i].PublishedOn = news[i].PublishedOn.In(warsaw)
+	}
+	if err := loadRouteIDs(db, news); err != nil {
+		return err
+	}
+	return loadPeriods(db, news)
+}
+
+// loadPeriods fills Periods of news
+func loadPeriods(db *sqlx.DB, news []NewsItem) error {
+	if len(news) == 0 {
+		return nil
+	}
+
+	urls := make([]string, len(news))
+	for i := range news {
+		urls[i] = news[i].Url
+		news[i].Periods = []Period{}
+	}
+	query, args, err := sqlx.In(`SELECT url, start_time, end_time FROM news_periods WHERE url IN (?) ORDER BY rowid`, urls)
+	if err != nil {
+		return err
+	}
+
+	periods := []struct {
+		Url string `db:"url"`
+		Period
+	}{}
+	if err := db.Select(&periods, db.Rebind(query), args...); err != nil {
+		return err
+	}
+
+	byUrl := make(map[string][]Period)
+	for _, period := range periods {
+		for _, t := range []*time.Time{period.Start, period.End} {
+			if t != nil {
+				*t = t.In(warsaw)
+			}
+		}
+		byUrl[period.Url] = append(byUrl[period.Url], period.Period)
+	}
+	for i := range news {
+		if newsPeriods, ok := byUrl[news[i].Url]; ok {
+			news[i].Periods = newsPeriods
+		}
+	}
+	return nil
+}
+
+// loadRouteIDs fills RouteIDs of news with lines linked to them
+func loadRouteIDs(db *sqlx.DB, news []NewsItem) error {
+	if len(news) == 0 {
+		return nil
+	}
+
+	urls := make([]string, len(news))
+	for i := range news {
+		urls[i] = news[i].Url
+		news[i].RouteIDs = []string{}
+	}
+	query, args, err := sqlx.In(`SELECT url, route_id FROM news_routes WHERE url IN (?) ORDER BY rowid`, urls)
+	if err != nil {
+		return err
+	}
+
+	links := []struct {
+		Url     string `db:"url"`
+		RouteID string `db:"route_id"`
+	}{}
+	if err := db.Select(&links, db.Rebind(query), args...); err != nil {
+		return err
+	}
+
+	byUrl := make(map[string][]string)
+	for _, link := range links {
+		byUrl[link.Url] = append(byUrl[link.Url], link.RouteID)
+	}
+	for i := range news {
+		if routeIDs, ok := byUrl[news[i].Url]; ok {
+			news[i].RouteIDs = routeIDs
+		}
+	}
+	return nil
+}
+
 const itemsPerPage = 10
 
 func getNews(db *sqlx.DB, limit int, page int) []NewsItem {
@@ -73,5 +262,25 @@ func getNews(db *sqlx.DB, limit int, page int) []NewsItem {
 		SELECT * FROM news
 		ORDER BY published_on DESC
 		LIMIT $1 OFFSET $2`, limit, offset)
+	if err := loadNewsDetails(db, news); err != nil {
+		log.Print(err)
+	}
 	return news
 }
+
+// getNewsActiveAt returns news with a period including given time,
+// or without periods and published after since
+func getNewsActiveAt(db *sqlx.DB, now, since time.Time) ([]NewsItem, error) {
+	news := []NewsItem{}
+	err := db.Select(&news, `
+		SELECT * FROM news
+		WHERE url IN (
+			SELECT url FROM news_periods
+			WHERE (start_time IS NULL OR start_time <= $1) AND (end_time IS NULL OR end_time > $1))
+		OR (published_on >= $2 AND url NOT IN (SELECT url FROM news_periods))
+		ORDER BY published_on DESC`, now.UTC(), since.UTC())
+	if err != nil {
+		return news, err
+	}
+	return news, loadNewsDetails(db, news)
+}
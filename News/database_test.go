@@ -0,0 +1,59 @@
+package News
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestUncrawledNewsKeepDetails(t *testing.T) {
+	db := sqlx.MustConnect("sqlite3", ":memory:")
+	defer db.Close()
+	db.MustExec(schema)
+	addNewsColumn(db, "affects_days_unparsed", "BOOLEAN NOT NULL DEFAULT 0")
+
+	start := time.Date(2018, 10, 3, 0, 0, 0, 0, warsaw)
+	news := []NewsItem{{Url: "a", PublishedOn: start, RouteIDs: []string{"33"}, Periods: []Period{{Start: &start}}, crawled: true}}
+	insertNewsIntoDB(db, news)
+	saveNewsPeriods(db, news)
+	linkNewsToRoutes(db, news)
+
+	// the article couldn't be read again
+	news = []NewsItem{{Url: "a"}}
+	saveNewsPeriods(db, news)
+	linkNewsToRoutes(db, news)
+
+	result, err := ActiveNews(db, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || !reflect.DeepEqual(result[0].RouteIDs, []string{"33"}) ||
+		len(result[0].Periods) != 1 || !result[0].Periods[0].Start.Equal(start) {
+		t.Errorf(`Wrong result. Got "%+v", expected news "a" with route 33 from %v`, result, start)
+	}
+}
+
+func TestMigratePublishedOn(t *testing.T) {
+	db := sqlx.MustConnect("sqlite3", ":memory:")
+	defer db.Close()
+	db.MustExec(schema)
+
+	// older versions stored Warsaw wall clock labelled as UTC
+	db.MustExec(`INSERT INTO news (url, published_on) VALUES ('a', $1)`, time.Date(2018, 10, 3, 12, 0, 0, 0, time.UTC))
+	expected := time.Date(2018, 10, 3, 12, 0, 0, 0, warsaw)
+
+	// migrated only once
+	for i := 0; i < 2; i++ {
+		migratePublishedOn(db)
+
+		var publishedOn time.Time
+		if err := db.Get(&publishedOn, `SELECT published_on FROM news WHERE url = 'a'`); err != nil {
+			t.Fatal(err)
+		}
+		if !publishedOn.Equal(expected) {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, publishedOn, expected)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package News
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Line is a line from the timetable which news can refer to
+type Line struct {
+	ID    string
+	IsBus bool
+}
+
+// Lines returns lines currently in service
+type Lines func() ([]Line, error)
+
+var lineSeparators = regexp.MustCompile(`[\s,;:/()]+`)
+var lineRangeDashes = regexp.MustCompile(`\s*[-–—]\s*`)
+var lineRangePattern = regexp.MustCompile(`^([0-9]+)-([0-9]+)$`)
+var nightLinePattern = regexp.MustCompile(`^N([0-9]+)$`)
+
+// Night buses are numbered 2xx
+func (line Line) isNight() bool {
+	return line.IsBus && len(line.ID) == 3 && line.ID[0] == '2'
+}
+
+// lineGroup returns which lines a word like "tramwaje", "autobusowe" or "nocnych" refers to
+func lineGroup(word string) func(Line) bool {
+	word = strings.ToLower(word)
+	switch {
+	case strings.HasPrefix(word, "tramwaj"):
+		return func(line Line) bool { return !line.IsBus }
+	case strings.HasPrefix(word, "autobus"):
+		return func(line Line) bool { return line.IsBus && !line.isNight() }
+	case strings.HasPrefix(word, "nocn"):
+		return Line.isNight
+	}
+	return nil
+}
+
+// parseAffectedLines returns IDs of known lines listed in "Dotyczy linii" paragraph, in order of lines.
+// It understands lists like "0L, 0P, 1 i A", ranges like "100-115", night lines written as "N240"
+// and groups: "wszystkie linie", "wszystkie tramwaje", "linie nocne". A group only selects its lines
+// when it follows "wszystkie" or no lines are listed, otherwise it just labels the list, as in "tramwaje: 1, 2".
+func parseAffectedLines(text string, lines []Line) []string {
+	known := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		known[line.ID] = true
+	}
+
+	affected := map[string]bool{}
+	var groups []func(Line) bool
+	explicit, everything := false, false
+	for _, token := range lineSeparators.Split(lineRangeDashes.ReplaceAllString(text, "-"), -1) {
+		token = strings.Trim(token, ".")
+		if known[token] {
+			affected[token] = true
+			explicit = true
+		} else if match := nightLinePattern.FindStringSubmatch(token); match != nil && known[match[1]] {
+			affected[match[1]] = true
+			explicit = true
+		} else if match := lineRangePattern.FindStringSubmatch(token); match != nil {
+			from, _ := strconv.Atoi(match[1])
+			to, _ := strconv.Atoi(match[2])
+			for _, line := range lines {
+				if number, err := strconv.Atoi(line.ID); err == nil && number >= from && number <= to {
+					affected[line.ID] = true
+				}
+			}
+			explicit = true
+		} else if strings.ToLower(token) == "wszystkie" {
+			everything = true
+		} else if group := lineGroup(token); group != nil {
+			groups = append(groups, group)
+		}
+	}
+
+	if everything && len(groups) == 0 {
+		groups = append(groups, func(Line) bool { return true })
+	}
+	if everything || !explicit {
+		for _, line := range lines {
+			for _, group := range groups {
+				if group(line) {
+					affected[line.ID] = true
+				}
+			}
+		}
+	}
+
+	routeIDs := []string{}
+	for _, line := range lines {
+		if affected[line.ID] {
+			routeIDs = append(routeIDs, line.ID)
+			delete(affected, line.ID)
+		}
+	}
+	return routeIDs
+}
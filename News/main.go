@@ -1,6 +1,7 @@
 package News
 
 import (
+	"log"
 	"net/http"
 	"time"
 
@@ -8,16 +9,22 @@ import (
 )
 
 type NewsItem struct {
-	Url          string    `db:"url"`
-	Title        string    `db:"title"`
-	PublishedOn  time.Time `db:"published_on"`
-	Synopsis     string    `db:"synopsis"`
-	AffectsLines string    `db:"affects_lines"`
-	AffectsDay   string    `db:"affects_days"`
-	Body         string    `db:"body"`
+	Url                string    `db:"url"`
+	Title              string    `db:"title"`
+	PublishedOn        time.Time `db:"published_on"`
+	Synopsis           string    `db:"synopsis"`
+	AffectsLines       string    `db:"affects_lines"`
+	AffectsDay         string    `db:"affects_days"`
+	Body               string    `db:"body"`
+	RouteIDs           []string  `db:"-"`
+	Periods            []Period  `db:"-"`
+	AffectsDayUnparsed bool      `db:"affects_days_unparsed"`
+	// false when the article couldn't be read, so its stored details are kept
+	crawled bool
 }
 
-func UpdateNews(db *sqlx.DB) {
+// UpdateNews crawls recent news and links them to affected lines out of given ones
+func UpdateNews(db *sqlx.DB, lines Lines) {
 	seedUrl := "http://mpk.wroc.pl/informacje/zmiany-w-komunikacji?page=%d"
 
 	timeout := time.Duration(5 * time.Second)
@@ -28,4 +35,15 @@ func UpdateNews(db *sqlx.DB) {
 	news := getNewsStubs(client, seedUrl)
 	fillOutNewsStubs(client, news)
 	insertNewsIntoDB(db, news)
+	saveNewsPeriods(db, news)
+
+	known, err := lines()
+	if err != nil {
+		log.Printf("Can't link news to lines: %s", err)
+		return
+	}
+	for i := range news {
+		news[i].RouteIDs = parseAffectedLines(news[i].AffectsLines, known)
+	}
+	linkNewsToRoutes(db, news)
 }
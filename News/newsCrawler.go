@@ -37,12 +37,14 @@ func crawlNews(client http.Client, newsStub *NewsItem, chFinished chan bool) {
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
 		log.Printf("status code error: %d %s", res.StatusCode, res.Status)
+		return
 	}
 
 	// Load the HTML document
 	doc, err := goquery.NewDocumentFromReader(res.Body)
 	if err != nil {
 		log.Print(err)
+		return
 	}
 
 	pageTitle := doc.Find(".page-title")
@@ -63,8 +65,14 @@ func crawlNews(client http.Client, newsStub *NewsItem, chFinished chan bool) {
 	newsStub.Title = cleanUpTitle(title)
 	newsStub.PublishedOn = parsePublishedDateTime(publishedOn)
 	newsStub.AffectsDay = affectsDays
+	newsStub.Periods, err = parseAffectedDays(affectsDays, newsStub.PublishedOn)
+	newsStub.AffectsDayUnparsed = err != nil
+	if err != nil {
+		log.Printf("Can't parse affectsDays '%s' for url %s", affectsDays, url)
+	}
 	newsStub.AffectsLines = affectsLines
 	newsStub.Body = fixImageUrls(body)
+	newsStub.crawled = true
 
 	log.Printf("Found news article. Title: '%s', publishedOn: '%s', affectsLines: '%s', affectsDays: '%s'", title, publishedOn, affectsLines, affectsDays)
 }
@@ -118,7 +126,7 @@ func cleanUpTitle(title string) string {
 
 func parsePublishedDateTime(publishedOn string) time.Time {
 	layout := "02.01.2006 15:04"
-	t, err := time.Parse(layout, publishedOn)
+	t, err := time.ParseInLocation(layout, publishedOn, warsaw)
 	if err != nil {
 		log.Println(err)
 	}
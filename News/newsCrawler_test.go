@@ -2,7 +2,10 @@ package News
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFixImageUrls(t *testing.T) {
@@ -55,3 +58,97 @@ func TestCleanUpTitle(t *testing.T) {
 		}
 	}
 }
+
+func TestParseAffectedLines(t *testing.T) {
+	lines := []Line{{"0L", false}, {"1", false}, {"33", false}, {"A", true}, {"K", true}, {"100", true}, {"110", true}, {"115", true}, {"240", true}, {"251", true}}
+	tables := []struct {
+		affectsLines string
+		expected     []string
+	}{
+		{`0L, 1, 33 i A`, []string{"0L", "1", "33", "A"}},
+		{`100 – 115; K.`, []string{"K", "100", "110", "115"}},
+		{`N240, 999`, []string{"240"}},
+		{`wszystkie linie`, []string{"0L", "1", "33", "A", "K", "100", "110", "115", "240", "251"}},
+		{`wszystkie tramwaje oraz linie 110`, []string{"0L", "1", "33", "110"}},
+		{`tramwajowe: 1, 33`, []string{"1", "33"}},
+		{`linie nocne`, []string{"240", "251"}},
+		{`autobusy`, []string{"A", "K", "100", "110", "115"}},
+		{``, []string{}},
+	}
+
+	for _, table := range tables {
+		result := parseAffectedLines(table.affectsLines, lines)
+		if !reflect.DeepEqual(result, table.expected) {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, table.expected)
+		}
+	}
+}
+
+func TestParseAffectedDays(t *testing.T) {
+	publishedOn := time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC)
+	describe := func(periods []Period) string {
+		var parts []string
+		for _, period := range periods {
+			start, end := "…", "…"
+			if period.Start != nil {
+				start = period.Start.Format("2006-01-02 15:04")
+			}
+			if period.End != nil {
+				end = period.End.Format("2006-01-02 15:04")
+			}
+			parts = append(parts, start+" - "+end)
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	tables := []struct {
+		affectsDay string
+		expected   string
+	}{
+		{`16.09.2018`, `2018-09-16 00:00 - 2018-09-17 00:00`},
+		{`9 i 16.09.2018r.`, `2018-09-09 00:00 - 2018-09-10 00:00, 2018-09-16 00:00 - 2018-09-17 00:00`},
+		{`od 3.10 do odwołania`, `2018-10-03 00:00 - …`},
+		{`do 7.10.2018`, `… - 2018-10-08 00:00`},
+		{`3-7.10.2018`, `2018-10-03 00:00 - 2018-10-08 00:00`},
+		{`od 6.10.2018 od godz. 22:00 do 8.10.2018 do godz. 4:30`, `2018-10-06 22:00 - 2018-10-08 04:30`},
+		{`13.10 (sobota) w godz. 22:00 - 4:30`, `2018-10-13 22:00 - 2018-10-14 04:30`},
+		{`28.12 - 3.01.2019`, `2018-12-28 00:00 - 2019-01-04 00:00`},
+		{`od 28.12 do 3.01`, `2018-12-28 00:00 - 2019-01-04 00:00`},
+		{`5 i 6 stycznia`, `2019-01-05 00:00 - 2019-01-06 00:00, 2019-01-06 00:00 - 2019-01-07 00:00`},
+		{`6, 13; 20.10`, `2018-10-06 00:00 - 2018-10-07 00:00, 2018-10-13 00:00 - 2018-10-14 00:00, 2018-10-20 00:00 - 2018-10-21 00:00`},
+		{``, ``},
+	}
+
+	for _, table := range tables {
+		periods, err := parseAffectedDays(table.affectsDay, publishedOn)
+		if err != nil {
+			t.Errorf(`Can't parse "%s": %s`, table.affectsDay, err)
+		}
+		if result := describe(periods); result != table.expected {
+			t.Errorf(`Wrong result. Got "%s", expected: "%s"`, result, table.expected)
+		}
+	}
+
+	for _, affectsDay := range []string{`weekendy`, `31.02.2018`, `od`, `16 i 17`} {
+		if _, err := parseAffectedDays(affectsDay, publishedOn); err != errUnknownDays {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, err, errUnknownDays)
+		}
+	}
+}
+
+func TestParsePublishedDateTime(t *testing.T) {
+	tables := []struct {
+		publishedOn string
+		expected    time.Time
+	}{
+		{`01.10.2018 12:00`, time.Date(2018, 10, 1, 10, 0, 0, 0, time.UTC)},
+		{`10.12.2018 08:30`, time.Date(2018, 12, 10, 7, 30, 0, 0, time.UTC)},
+	}
+
+	for _, table := range tables {
+		result := parsePublishedDateTime(table.publishedOn)
+		if !result.Equal(table.expected) {
+			t.Errorf(`Wrong result. Got "%v", expected: "%v"`, result, table.expected)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package News
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Period is a time range in which changes described by news are in effect. Missing Start or End leaves it open.
+type Period struct {
+	Start *time.Time `db:"start_time" json:",omitempty"`
+	End   *time.Time `db:"end_time" json:",omitempty"`
+}
+
+var warsaw, _ = time.LoadLocation("Europe/Warsaw")
+
+var errUnknownDays = errors.New("unrecognised days")
+
+// Dates without a year which would be over half a year old at publication refer to the next year
+const pastDatesLimit = 183
+
+var dayTokenPattern = regexp.MustCompile(`\d{1,2}:\d{2}|\d{1,2}\.\d{1,2}(?:\.\d{2,4})?|\d+|\pL+\.?|[-–—,;]|\S`)
+
+const (
+	tokenDate = iota
+	tokenTime
+	tokenNumber
+	tokenWord
+	tokenDash
+	tokenSeparator
+)
+
+// dayToken is a piece of "Obowiązuje w dniach" text. values are day, month and year of dates,
+// hour and minute of times and value of numbers.
+type dayToken struct {
+	kind   int
+	text   string
+	values [3]int
+}
+
+var monthNames = map[string]int{
+	"stycznia": 1, "lutego": 2, "marca": 3, "kwietnia": 4, "maja": 5, "czerwca": 6,
+	"lipca": 7, "sierpnia": 8, "września": 9, "października": 10, "listopada": 11, "grudnia": 12,
+}
+
+var hourWords = map[string]bool{"godz": true, "godzin": true, "godziny": true, "godzinie": true}
+
+var fillerWords = map[string]bool{
+	"r": true, "roku": true, "dnia": true, "dniu": true, "dniach": true, "dni": true, "w": true, "we": true, "od": true, "do": true,
+	"poniedziałek": true, "wtorek": true, "środa": true, "środę": true, "czwartek": true, "piątek": true,
+	"sobota": true, "sobotę": true, "niedziela": true, "niedzielę": true,
+}
+
+func tokenizeDays(text string) ([]dayToken, error) {
+	var tokens []dayToken
+	for _, match := range dayTokenPattern.FindAllString(strings.ToLower(text), -1) {
+		token := dayToken{text: strings.TrimSuffix(match, ".")}
+		switch {
+		case strings.Contains(match, ":"):
+			token.kind = tokenTime
+		case strings.Contains(match, ".") && match[0] >= '0' && match[0] <= '9':
+			token.kind = tokenDate
+		case match[0] >= '0' && match[0] <= '9':
+			token.kind = tokenNumber
+		case match == "-" || match == "–" || match == "—":
+			token.kind = tokenDash
+		case match == "," || match == ";" || match == "i" || match == "oraz":
+			token.kind = tokenSeparator
+		case match == "(" || match == ")" || match == ".":
+			continue
+		case len(token.text) > 1 || token.text == "r" || token.text == "w":
+			token.kind = tokenWord
+		default:
+			return nil, errUnknownDays
+		}
+
+		if token.kind != tokenWord {
+			for i, value := range strings.FieldsFunc(match, func(r rune) bool { return r == '.' || r == ':' }) {
+				token.values[i], _ = strconv.Atoi(value)
+			}
+		}
+		if token.kind == tokenDate && token.values[2] > 0 && token.values[2] < 100 {
+			token.values[2] += 2000
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+// dayPoint is a day and time as written in news, month and year may be missing
+type dayPoint struct {
+	day, month, year int
+	hour, minute     int
+	hasTime          bool
+	yearGiven        bool
+}
+
+func (point *dayPoint) setTime(token dayToken) error {
+	if point.hasTime || token.values[0] > 24 || token.values[1] > 59 {
+		return errUnknownDays
+	}
+	point.hour, point.minute, point.hasTime = token.values[0], token.values[1], true
+	return nil
+}
+
+// parseDayPoint reads day and time like "16.09.2018r. od godz. 22:00" or "3 października"
+func parseDayPoint(tokens []dayToken) (*dayPoint, error) {
+	var point dayPoint
+	hourNext := false
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		switch {
+		case hourNext && (token.kind == tokenNumber || token.kind == tokenDate || token.kind == tokenTime):
+			// godz. 22, godz. 22.00, godz. 22:00
+			if err := point.setTime(token); err != nil {
+				return nil, err
+			}
+			hourNext = false
+		case token.kind == tokenTime:
+			if err := point.setTime(token); err != nil {
+				return nil, err
+			}
+		case token.kind == tokenDate && point.day == 0 && token.values[1] > 0:
+			point.day, point.month, point.year = token.values[0], token.values[1], token.values[2]
+		case token.kind == tokenNumber && point.day == 0 && i+1 < len(tokens) && monthNames[tokens[i+1].text] > 0:
+			point.day, point.month = token.values[0], monthNames[tokens[i+1].text]
+			i++
+			if i+1 < len(tokens) && tokens[i+1].kind == tokenNumber && tokens[i+1].values[0] >= 1000 {
+				point.year = tokens[i+1].values[0]
+				i++
+			}
+		case token.kind == tokenNumber && point.day == 0:
+			point.day = token.values[0]
+		case token.kind == tokenWord && hourWords[token.text]:
+			hourNext = true
+		case token.kind == tokenWord && fillerWords[token.text]:
+		default:
+			return nil, errUnknownDays
+		}
+	}
+
+	if (point.day == 0 && !point.hasTime) || point.day > 31 || point.month > 12 {
+		return nil, errUnknownDays
+	}
+	point.yearGiven = point.year != 0
+	return &point, nil
+}
+
+// dayRange is one of periods listed in news: a single day, a range or a range open on either side
+type dayRange struct {
+	start, end *dayPoint
+	single     bool
+}
+
+// parseDayRange reads periods like "16.09.2018", "od 3.10 do odwołania", "do 7.10", "3-7.10.2018"
+// or "16.09 od godz. 10:00 do 14:00"
+func parseDayRange(tokens []dayToken) (dayRange, error) {
+	from := tokens[0].kind == tokenWord && tokens[0].text == "od"
+	if from {
+		tokens = tokens[1:]
+	}
+
+	left, right, split := tokens, []dayToken(nil), false
+	for i, token := range tokens {
+		if token.kind == tokenDash || (token.kind == tokenWord && token.text == "do") {
+			left, right, split = tokens[:i], tokens[i+1:], true
+			break
+		}
+	}
+
+	var result dayRange
+	var err error
+	if len(left) > 0 {
+		if result.start, err = parseDayPoint(left); err != nil {
+			return result, err
+		}
+	}
+	if !split {
+		if result.start == nil {
+			return result, errUnknownDays
+		}
+		result.single = !from
+		return result, nil
+	}
+
+	if len(right) == 1 && right[0].kind == tokenWord &&
+		(strings.HasPrefix(right[0].text, "odwoł") || strings.HasPrefix(right[0].text, "odwol")) {
+		return result, nil
+	}
+	result.end, err = parseDayPoint(right)
+	return result, err
+}
+
+// fillDates completes points with month and year taken from neighbouring points,
+// as in "9 i 16.09.2018" or "28.12 - 3.01.2019", or from the date of publication.
+func fillDates(points []*dayPoint, publishedOn time.Time) error {
+	for i, point := range points {
+		if point.day == 0 || point.month != 0 {
+			continue
+		}
+		for _, j := range neighbours(i, len(points)) {
+			if points[j].month != 0 {
+				point.month = points[j].month
+				break
+			}
+		}
+		if point.month == 0 {
+			return errUnknownDays
+		}
+	}
+
+	for i, point := range points {
+		if point.day == 0 || point.year != 0 {
+			continue
+		}
+		for _, j := range neighbours(i, len(points)) {
+			other := points[j]
+			if other.year == 0 {
+				continue
+			}
+			point.year = other.year
+			if j > i && other.month < point.month {
+				point.year--
+			} else if j < i && other.month > point.month {
+				point.year++
+			}
+			break
+		}
+		if point.year == 0 {
+			point.year = publishedOn.Year()
+			if publishedOn.Sub(point.date()) > pastDatesLimit*24*time.Hour {
+				point.year++
+			}
+		}
+	}
+
+	for _, point := range points {
+		if point.day != 0 && point.date().Day() != point.day {
+			return errUnknownDays
+		}
+	}
+	return nil
+}
+
+// neighbours returns indices of points after i, nearest first, and then of points before it
+func neighbours(i, count int) []int {
+	var indices []int
+	for j := i + 1; j < count; j++ {
+		indices = append(indices, j)
+	}
+	for j := i - 1; j >= 0; j-- {
+		indices = append(indices, j)
+	}
+	return indices
+}
+
+func (point dayPoint) date() time.Time {
+	return time.Date(point.year, time.Month(point.month), point.day, 0, 0, 0, 0, warsaw)
+}
+
+// time returns moment of the point. Points without time start at midnight or, at the end of a period,
+// last until the end of the day.
+func (point dayPoint) time(end bool) time.Time {
+	if !point.hasTime && end {
+		return point.date().AddDate(0, 0, 1)
+	}
+	return point.date().Add(time.Duration(point.hour)*time.Hour + time.Duration(point.minute)*time.Minute)
+}
+
+// parseAffectedDays turns "Obowiązuje w dniach" text like "16.09.2018", "9 i 16.09.2018r."
+// or "od 3.10 od godz. 22:00 do odwołania" into periods. Dates without a year are taken from around publication.
+func parseAffectedDays(text string, publishedOn time.Time) ([]Period, error) {
+	tokens, err := tokenizeDays(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []dayRange
+	var points []*dayPoint
+	start := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && tokens[i].kind != tokenSeparator {
+			continue
+		}
+		if i > start {
+			r, err := parseDayRange(tokens[start:i])
+			if err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, r)
+			for _, point := range []*dayPoint{r.start, r.end} {
+				if point != nil {
+					points = append(points, point)
+				}
+			}
+		}
+		start = i + 1
+	}
+
+	// "16.09 od 10:00 do 14:00" ends on the day it starts
+	for _, r := range ranges {
+		if r.start != nil && r.end != nil && r.end.day == 0 {
+			r.end.day, r.end.month, r.end.year = r.start.day, r.start.month, r.start.year
+		} else if r.start != nil && r.end != nil && r.start.day == 0 {
+			r.start.day, r.start.month, r.start.year = r.end.day, r.end.month, r.end.year
+		}
+	}
+	if err := fillDates(points, publishedOn); err != nil {
+		return nil, err
+	}
+
+	periods := []Period{}
+	for _, r := range ranges {
+		var period Period
+		if r.start != nil {
+			if r.start.day == 0 {
+				return nil, errUnknownDays
+			}
+			start := r.start.time(false)
+			period.Start = &start
+		}
+		if r.single {
+			end := r.start.date().AddDate(0, 0, 1)
+			period.End = &end
+		} else if r.end != nil {
+			if r.end.day == 0 {
+				return nil, errUnknownDays
+			}
+			end := r.end.time(true)
+			if period.Start != nil && !end.After(*period.Start) {
+				if !r.end.yearGiven && r.end.date().Before(r.start.date()) {
+					end = end.AddDate(1, 0, 0)
+				} else {
+					// overnight, as in "22:00-4:30"
+					end = end.AddDate(0, 0, 1)
+				}
+			}
+			period.End = &end
+		}
+		periods = append(periods, period)
+	}
+	return periods, nil
+}
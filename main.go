@@ -3,43 +3,188 @@ package main
 import (
 	"./GTFS"
 	"./News"
+	"flag"
+	"fmt"
 	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
 	"github.com/robfig/cron"
 	"log"
 	"net/http"
+	"os"
+	"time"
 )
 
+var feedPath = flag.String("feed", "", "serve GTFS feed from given zip, kept in memory, instead of Neo4j")
+var transferRadius = flag.Float64("transfer-radius", GTFS.DefaultTransferRadius, "maximum walking distance between platforms for transfers, in metres")
+var tripUpdatesSource = flag.String("trip-updates", "", "GTFS-Realtime TripUpdates feed, URL or local file, polled every 30 seconds")
+var vehiclePositionsSource = flag.String("vehicle-positions", "", "GTFS-Realtime VehiclePositions feed, URL or local file, polled every 30 seconds")
+var realtimeStaleAfter = flag.Duration("realtime-stale-after", GTFS.DefaultRealtimeStaleAfter, "age of realtime data after which departures fall back to timetable")
+var holidaysPath = flag.String("holidays", "", "JSON file with extra dates and their day types, e.g. {\"2018-12-24\": \"saturday\"}")
+
+func openHolidays() *GTFS.HolidayCalendar {
+	if *holidaysPath == "" {
+		return GTFS.NewHolidayCalendar(nil)
+	}
+
+	holidays, err := GTFS.LoadHolidayCalendar(*holidaysPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return holidays
+}
+
+func openStore() GTFS.Store {
+	holidays := openHolidays()
+	if *feedPath == "" {
+		return GTFS.OpenDB(holidays, *transferRadius)
+	}
+
+	store, err := GTFS.OpenFeed(*feedPath, holidays, *transferRadius)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return store
+}
+
+// openRealtime starts polling GTFS-Realtime feed, returning nil if source isn't given.
+func openRealtime(c *cron.Cron, source string) *GTFS.Realtime {
+	if source == "" {
+		return nil
+	}
+
+	realtime := GTFS.NewRealtime(source, *realtimeStaleAfter)
+	realtime.Update()
+	c.AddFunc("@every 30s", func() { realtime.Update() })
+	return realtime
+}
+
+// storeLines lists lines from the GTFS store, so that news can be linked to them.
+func storeLines(store GTFS.Store) News.Lines {
+	return func() ([]News.Line, error) {
+		routes, err := store.GetAllRouteIDs()
+		if err != nil {
+			return nil, err
+		}
+
+		lines := make([]News.Line, len(routes))
+		for i, route := range routes {
+			lines[i] = News.Line{ID: route.ID, IsBus: route.IsBus}
+		}
+		return lines, nil
+	}
+}
+
+// newsAlerts publishes active news as GTFS-Realtime alerts.
+func newsAlerts(db *sqlx.DB) GTFS.AlertSource {
+	return func(now time.Time) ([]GTFS.Alert, error) {
+		news, err := News.ActiveNews(db, now)
+		if err != nil {
+			return nil, err
+		}
+
+		alerts := make([]GTFS.Alert, len(news))
+		for i, newsItem := range news {
+			periods := make([]GTFS.AlertPeriod, len(newsItem.Periods))
+			for j, period := range newsItem.Periods {
+				periods[j] = GTFS.AlertPeriod(period)
+			}
+			if len(periods) == 0 {
+				publishedOn := newsItem.PublishedOn
+				periods = append(periods, GTFS.AlertPeriod{Start: &publishedOn})
+			}
+
+			alerts[i] = GTFS.Alert{
+				ID:            newsItem.Url,
+				URL:           newsItem.Url,
+				Header:        newsItem.Title,
+				Description:   newsItem.Synopsis,
+				RouteIDs:      newsItem.RouteIDs,
+				ActivePeriods: periods,
+			}
+		}
+		return alerts, nil
+	}
+}
+
+func runImport(path string) {
+	store := GTFS.OpenDB(nil, *transferRadius)
+	report, err := store.ImportFeed(path)
+	fmt.Print(report)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if report.HasErrors() {
+		os.Exit(1)
+	}
+}
+
 func main() {
-	driver := GTFS.OpenDB()
+	flag.Parse()
+	if flag.Arg(0) == "import" {
+		if flag.NArg() != 2 {
+			log.Fatalf("usage: %s import <gtfs.zip>", os.Args[0])
+		}
+		runImport(flag.Arg(1))
+		return
+	}
+
+	store := openStore()
 	newsDb := News.OpenDatabase()
 
+	c := cron.New()
+	tripUpdates := openRealtime(c, *tripUpdatesSource)
+	vehiclePositions := openRealtime(c, *vehiclePositionsSource)
+	if tripUpdates != nil || vehiclePositions != nil {
+		store = GTFS.WithRealtime(store, tripUpdates, vehiclePositions)
+	}
+	// nil without realtime feeds, vehicle handlers respond with 503 then
+	vehicles, _ := store.(GTFS.VehicleSource)
+
 	router := mux.NewRouter().UseEncodedPath()
-	router.HandleFunc("/stops", GTFS.StopsHandler(driver))
-	router.HandleFunc("/stops/{stopNames}/departures", GTFS.StopsUpcomingDeparturesHandler(driver))
-	router.HandleFunc("/stops/and/routes", GTFS.StopsAndRoutesHandler(driver))
-	router.HandleFunc("/routes", GTFS.RoutesHandler(driver))
-	router.HandleFunc("/routes/variants/id/{routeID}", GTFS.RoutesVariantsByIdHandler(driver))
-	router.HandleFunc("/routes/variants/stop/{stopName}", GTFS.RoutesVariantsByStopNameHandler(driver))
-	router.HandleFunc("/route/{routeID}/timetable/at/{stopName}/direction/{direction}", GTFS.RoutesTimeTableHandler(driver))
-	router.HandleFunc("/route/{routeID}/info", GTFS.RouteInfoHandler(driver))
-	router.HandleFunc("/route/{routeID}/directions", GTFS.RouteDirectionsHandler(driver))
-	router.HandleFunc("/route/{routeID}/directions/through/{stopName}", GTFS.RouteDirectionsThroughStopHandler(driver))
-	router.HandleFunc("/route/{routeID}/stops", GTFS.RouteStopsHandler(driver))
-	router.HandleFunc("/route/{routeID}/map/at/{stopName}/direction/{direction}", GTFS.RouteMapHandler(driver))
-	router.HandleFunc("/trip/{tripID}/timeline", GTFS.TripTimelineHandler(driver))
-	router.HandleFunc("/trip/{tripID}/map", GTFS.TripMapHandler(driver))
+	router.HandleFunc("/stops", GTFS.StopsHandler(store))
+	router.HandleFunc("/stops/{stopNames}/departures", GTFS.StopsUpcomingDeparturesHandler(store))
+	router.HandleFunc("/stops/id/{stopID}", GTFS.StopByIDHandler(store))
+	router.HandleFunc("/stops/id/{stopIDs}/departures", GTFS.StopsByIDUpcomingDeparturesHandler(store))
+	router.HandleFunc("/stops/id/{stopID}/transfers", GTFS.StopTransfersHandler(store))
+	router.HandleFunc("/stops/code/{code}", GTFS.StopByCodeHandler(store))
+	router.HandleFunc("/stops/near", GTFS.StopsNearHandler(store))
+	router.HandleFunc("/stops/search", GTFS.StopsSearchHandler(store))
+	router.HandleFunc("/stations", GTFS.StationsHandler(store))
+	router.HandleFunc("/stations/{stationID}", GTFS.StationHandler(store))
+	router.HandleFunc("/stations/{stationID}/departures", GTFS.StationUpcomingDeparturesHandler(store))
+	router.HandleFunc("/stops/and/routes", GTFS.StopsAndRoutesHandler(store))
+	router.HandleFunc("/routes", GTFS.RoutesHandler(store))
+	router.HandleFunc("/routes/variants/id/{routeID}", GTFS.RoutesVariantsByIdHandler(store))
+	router.HandleFunc("/routes/variants/stop/{stopName}", GTFS.RoutesVariantsByStopNameHandler(store))
+	router.HandleFunc("/routes/variants/stop/id/{stopID}", GTFS.RoutesVariantsByStopIDHandler(store))
+	router.HandleFunc("/route/{routeID}/timetable/at/{stopName}/direction/{direction}", GTFS.RoutesTimeTableHandler(store))
+	router.HandleFunc("/route/{routeID}/timetable/at/id/{stopID}/direction/{direction}", GTFS.RoutesTimeTableAtStopHandler(store))
+	router.HandleFunc("/route/{routeID}/info", GTFS.RouteInfoHandler(store))
+	router.HandleFunc("/route/{routeID}/directions", GTFS.RouteDirectionsHandler(store))
+	router.HandleFunc("/route/{routeID}/directions/through/{stopName}", GTFS.RouteDirectionsThroughStopHandler(store))
+	router.HandleFunc("/route/{routeID}/stops", GTFS.RouteStopsHandler(store))
+	router.HandleFunc("/route/{routeID}/map/at/{stopName}/direction/{direction}", GTFS.RouteMapHandler(store))
+	router.HandleFunc("/trip/{tripID}/timeline", GTFS.TripTimelineHandler(store))
+	router.HandleFunc("/trip/{tripID}/map", GTFS.TripMapHandler(store))
+	router.HandleFunc("/trip/{tripID}/vehicle", GTFS.TripVehicleHandler(vehicles))
+	router.HandleFunc("/journeys", GTFS.JourneysHandler(store))
+	router.HandleFunc("/isochrone", GTFS.IsochroneHandler(store))
+	router.HandleFunc("/vehicles", GTFS.VehiclesHandler(vehicles))
+	router.HandleFunc("/vehicles/scheduled", GTFS.ScheduledVehiclesHandler(store))
+	router.HandleFunc("/tiles/{z:[0-9]+}/{x:[0-9]+}/{y:[0-9]+}.mvt", GTFS.TilesHandler(store))
 	router.HandleFunc("/news/recent", News.RecentNewsHandler(newsDb))
 	router.HandleFunc("/news/page/{pageNum}", News.NewsHandler(newsDb))
+	router.HandleFunc("/gtfs-rt/alerts.{format:pb|json}", GTFS.AlertsHandler(store, newsAlerts(newsDb)))
 
 	go func() {
 		log.Fatal(http.ListenAndServe(":8080", router))
 	}()
 
-	c := cron.New()
-	c.AddFunc("@every 15m", func() { News.UpdateNews(newsDb) })
+	lines := storeLines(store)
+	c.AddFunc("@every 15m", func() { News.UpdateNews(newsDb, lines) })
 	c.Start()
 
-	News.UpdateNews(newsDb)
+	News.UpdateNews(newsDb, lines)
 
 	select {} // "sleep" forever
 }
@@ -1,3 +0,0 @@
-#!/bin/sh
-
-cat import.cql | cypher-shell -u neo4j -p password --format verbose